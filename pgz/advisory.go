@@ -0,0 +1,164 @@
+package pgz
+
+import (
+	"context"
+	"hash/fnv"
+	"strconv"
+	"time"
+
+	"github.com/ibrt/golang-errors/errorz"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	// ErrIDLockTimeout is an error ID.
+	ErrIDLockTimeout = errorz.ID("lock-timeout")
+)
+
+const (
+	advisoryUnlockTimeout = 5 * time.Second
+)
+
+// WithAdvisoryLock calls f while holding the advisory lock identified by the given key, waiting for it if needed.
+// Within a transaction, a transaction-level lock is taken, which is held until the transaction ends (i.e. not only for
+// the duration of f). Otherwise, a session-level lock is taken on a connection pinned until f returns.
+func WithAdvisoryLock(ctx context.Context, key string, f func(ctx context.Context) error) error {
+	return WithAdvisoryLockNamed(ctx, DefaultName, key, f)
+}
+
+// WithAdvisoryLockNamed is like WithAdvisoryLock, but for the named database.
+func WithAdvisoryLockNamed(ctx context.Context, name, key string, f func(ctx context.Context) error) error {
+	_, err := withAdvisoryLock(ctx, name, key, 0, false, f)
+	return errorz.MaybeWrap(err, errorz.SkipPackage())
+}
+
+// WithAdvisoryLockTimeout is like WithAdvisoryLock, but fails with ErrIDLockTimeout if the lock cannot be acquired
+// within the given timeout. Within a transaction, a timeout aborts the transaction.
+func WithAdvisoryLockTimeout(ctx context.Context, key string, timeout time.Duration, f func(ctx context.Context) error) error {
+	return WithAdvisoryLockTimeoutNamed(ctx, DefaultName, key, timeout, f)
+}
+
+// WithAdvisoryLockTimeoutNamed is like WithAdvisoryLockTimeout, but for the named database.
+func WithAdvisoryLockTimeoutNamed(ctx context.Context, name, key string, timeout time.Duration, f func(ctx context.Context) error) error {
+	_, err := withAdvisoryLock(ctx, name, key, timeout, false, f)
+	return errorz.MaybeWrap(err, errorz.SkipPackage())
+}
+
+// TryAdvisoryLock is like WithAdvisoryLock, but returns false without calling f if the lock is already held.
+func TryAdvisoryLock(ctx context.Context, key string, f func(ctx context.Context) error) (bool, error) {
+	return TryAdvisoryLockNamed(ctx, DefaultName, key, f)
+}
+
+// TryAdvisoryLockNamed is like TryAdvisoryLock, but for the named database.
+func TryAdvisoryLockNamed(ctx context.Context, name, key string, f func(ctx context.Context) error) (bool, error) {
+	acquired, err := withAdvisoryLock(ctx, name, key, 0, true, f)
+	return acquired, errorz.MaybeWrap(err, errorz.SkipPackage())
+}
+
+func withAdvisoryLock(ctx context.Context, name, key string, timeout time.Duration, try bool, f func(ctx context.Context) error) (bool, error) {
+	id := advisoryLockID(key)
+	acquired := false
+
+	if isInTx(ctx, name) {
+		// The lock is taken on the transaction connection, which must be released before calling f.
+		err := withConn(ctx, name, "advisory lock: "+key, func(conn *pgx.Conn) error {
+			var err error
+			acquired, err = acquireAdvisoryLock(ctx, conn, id, true, timeout, try)
+			return errorz.MaybeWrap(err, errorz.SkipPackage())
+		})
+		if err != nil || !acquired {
+			return false, errorz.MaybeWrap(err, errorz.SkipPackage())
+		}
+		return true, errorz.MaybeWrap(f(ctx), errorz.SkipPackage())
+	}
+
+	err := withConn(ctx, name, "advisory lock: "+key, func(conn *pgx.Conn) error {
+		var err error
+		if acquired, err = acquireAdvisoryLock(ctx, conn, id, false, timeout, try); err != nil || !acquired {
+			return errorz.MaybeWrap(err, errorz.SkipPackage())
+		}
+		defer releaseAdvisoryLock(conn, id)
+		return errorz.MaybeWrap(f(ctx), errorz.SkipPackage())
+	})
+
+	return acquired, errorz.MaybeWrap(err, errorz.SkipPackage())
+}
+
+func acquireAdvisoryLock(ctx context.Context, conn *pgx.Conn, id int64, inTx bool, timeout time.Duration, try bool) (bool, error) {
+	if try {
+		query := "SELECT pg_try_advisory_lock($1)"
+		if inTx {
+			query = "SELECT pg_try_advisory_xact_lock($1)"
+		}
+
+		acquired := false
+		err := conn.QueryRow(ctx, query, id).Scan(&acquired)
+		return acquired, errorz.MaybeWrap(err, errorz.SkipPackage())
+	}
+
+	query := "SELECT pg_advisory_lock($1)"
+	if inTx {
+		query = "SELECT pg_advisory_xact_lock($1)"
+	}
+
+	if timeout <= 0 {
+		_, err := conn.Exec(ctx, query, id)
+		return err == nil, errorz.MaybeWrap(err, errorz.SkipPackage())
+	}
+
+	// The lock_timeout setting is changed for the duration of the lock statement, then restored, so that it does not
+	// leak to the session or the rest of the transaction.
+	previous := ""
+	if err := conn.QueryRow(ctx, "SELECT current_setting('lock_timeout')").Scan(&previous); err != nil {
+		return false, errorz.Wrap(err, errorz.SkipPackage())
+	}
+
+	millis := timeout.Milliseconds()
+	if millis < 1 {
+		millis = 1
+	}
+
+	if _, err := conn.Exec(ctx, "SELECT set_config('lock_timeout', $1, $2)", strconv.FormatInt(millis, 10), inTx); err != nil {
+		return false, errorz.Wrap(err, errorz.SkipPackage())
+	}
+
+	_, err := conn.Exec(ctx, query, id)
+
+	if pgErr, ok := errorz.Unwrap(err).(*pgconn.PgError); ok && pgErr.Code == pgerrcode.LockNotAvailable {
+		err = errorz.Wrap(err, errorz.Prefix("advisory lock timeout"), ErrIDLockTimeout, errorz.SkipPackage())
+	}
+
+	if err != nil && inTx {
+		// The transaction is aborted, the setting is reverted on rollback.
+		return false, errorz.Wrap(err, errorz.SkipPackage())
+	}
+
+	if _, restoreErr := conn.Exec(ctx, "SELECT set_config('lock_timeout', $1, $2)", previous, inTx); restoreErr != nil && err == nil {
+		if !inTx {
+			releaseAdvisoryLock(conn, id)
+		}
+		return false, errorz.Wrap(restoreErr, errorz.SkipPackage())
+	}
+
+	return err == nil, errorz.MaybeWrap(err, errorz.SkipPackage())
+}
+
+// releaseAdvisoryLock releases a session-level advisory lock, closing the connection if that fails so that the lock is
+// not leaked when the connection is returned to the pool.
+func releaseAdvisoryLock(conn *pgx.Conn, id int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), advisoryUnlockTimeout)
+	defer cancel()
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_unlock($1)", id); err != nil {
+		_ = conn.Close(ctx)
+	}
+}
+
+// advisoryLockID hashes the given key to the int64 identifier of an advisory lock.
+func advisoryLockID(key string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return int64(h.Sum64())
+}
@@ -0,0 +1,124 @@
+package pgz_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ibrt/golang-errors/errorz"
+	"github.com/ibrt/golang-fixtures/fixturez"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ibrt/golang-inject-pg/pgz"
+)
+
+func (s *Suite) TestAdvisoryLock(ctx context.Context, t *testing.T) {
+	for _, lCtx := range []context.Context{ctx, newPgxContext(ctx, t)} {
+		called := false
+
+		err := pgz.WithAdvisoryLock(lCtx, "test-lock", func(fCtx context.Context) error {
+			called = true
+			requireAdvisoryLocked(lCtx, t, "test-lock", true)
+			requireAdvisoryLocked(lCtx, t, "test-other-lock", false)
+
+			err := pgz.WithAdvisoryLockTimeout(lCtx, "test-lock", 100*time.Millisecond, func(context.Context) error {
+				require.FailNow(t, "unexpected call")
+				return nil
+			})
+			require.EqualError(t, err, `advisory lock timeout: ERROR: canceling statement due to lock timeout (SQLSTATE 55P03)`)
+			require.Equal(t, pgz.ErrIDLockTimeout, errorz.GetID(err))
+
+			return errorz.Errorf("test error")
+		})
+		require.EqualError(t, err, "test error")
+		require.True(t, called)
+		requireAdvisoryLocked(lCtx, t, "test-lock", false)
+
+		err = pgz.WithAdvisoryLockTimeout(lCtx, "test-lock", time.Second, func(context.Context) error {
+			requireAdvisoryLocked(lCtx, t, "test-lock", true)
+			return nil
+		})
+		fixturez.RequireNoError(t, err)
+		requireAdvisoryLocked(lCtx, t, "test-lock", false)
+
+		err = pgz.NewTx(lCtx).Run(func(tCtx context.Context) error {
+			fixturez.RequireNoError(t, pgz.WithAdvisoryLockTimeout(tCtx, "test-lock", time.Second, func(context.Context) error {
+				requireAdvisoryLocked(lCtx, t, "test-lock", true)
+				return nil
+			}))
+
+			requireAdvisoryLocked(lCtx, t, "test-lock", true)
+
+			lockTimeout, err := pgz.SelectScalar[string](tCtx, `SHOW lock_timeout`)
+			fixturez.RequireNoError(t, err)
+			require.Equal(t, "0", lockTimeout)
+			return nil
+		})
+		fixturez.RequireNoError(t, err)
+		requireAdvisoryLocked(lCtx, t, "test-lock", false)
+
+		err = pgz.WithAdvisoryLock(lCtx, "test-lock", func(context.Context) error {
+			return pgz.NewTx(lCtx).Run(func(tCtx context.Context) error {
+				return pgz.WithAdvisoryLockTimeout(tCtx, "test-lock", 100*time.Millisecond, func(context.Context) error {
+					require.FailNow(t, "unexpected call")
+					return nil
+				})
+			})
+		})
+		require.Error(t, err)
+		require.Equal(t, pgz.ErrIDLockTimeout, errorz.GetID(err))
+		requireAdvisoryLocked(lCtx, t, "test-lock", false)
+
+		count, err := pgz.SelectScalar[int64](lCtx, `SELECT COUNT(*) FROM pg_locks WHERE locktype = 'advisory'`)
+		fixturez.RequireNoError(t, err)
+		require.Equal(t, int64(0), count)
+	}
+}
+
+// requireAdvisoryLocked checks whether the given lock is held by trying to acquire it from another connection.
+func (s *Suite) TestAdvisoryLock_Named(ctx context.Context, t *testing.T) {
+	for _, lCtx := range []context.Context{ctx, newPgxContext(ctx, t)} {
+		nCtx := newNamedTestContext(lCtx, t, "analytics")
+
+		err := pgz.WithAdvisoryLockNamed(nCtx, "analytics", "test-lock", func(context.Context) error {
+			requireAdvisoryLocked(lCtx, t, "test-lock", true)
+
+			acquired, err := pgz.TryAdvisoryLockNamed(nCtx, "analytics", "test-lock", func(context.Context) error {
+				require.FailNow(t, "unexpected call")
+				return nil
+			})
+			fixturez.RequireNoError(t, err)
+			require.False(t, acquired)
+
+			err = pgz.WithAdvisoryLockTimeoutNamed(nCtx, "analytics", "test-lock", 100*time.Millisecond, func(context.Context) error {
+				require.FailNow(t, "unexpected call")
+				return nil
+			})
+			require.Equal(t, pgz.ErrIDLockTimeout, errorz.GetID(err))
+			return nil
+		})
+		fixturez.RequireNoError(t, err)
+		requireAdvisoryLocked(lCtx, t, "test-lock", false)
+
+		// Within the transaction on the named database, the lock is held until the transaction ends.
+		err = pgz.NewTx(nCtx).SetDatabaseName("analytics").Run(func(tCtx context.Context) error {
+			acquired, err := pgz.TryAdvisoryLockNamed(tCtx, "analytics", "test-lock", func(context.Context) error {
+				return nil
+			})
+			fixturez.RequireNoError(t, err)
+			require.True(t, acquired)
+			requireAdvisoryLocked(lCtx, t, "test-lock", true)
+			return nil
+		})
+		fixturez.RequireNoError(t, err)
+		requireAdvisoryLocked(lCtx, t, "test-lock", false)
+	}
+}
+
+func requireAdvisoryLocked(ctx context.Context, t *testing.T, key string, locked bool) {
+	acquired, err := pgz.TryAdvisoryLock(ctx, key, func(context.Context) error {
+		return nil
+	})
+	fixturez.RequireNoError(t, err)
+	require.Equal(t, !locked, acquired)
+}
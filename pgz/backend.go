@@ -0,0 +1,402 @@
+package pgz
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"time"
+
+	"github.com/ibrt/golang-errors/errorz"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/jackc/pgx/v4/stdlib"
+)
+
+// backend wraps either a *sql.DB or a *pgxpool.Pool, depending on Config.EnablePgxPoolMode.
+type backend struct {
+	db           *sql.DB
+	pool         *pgxpool.Pool
+	maxIdleConns int
+	tracker      *tracker
+	observers    []Observer
+	pg           *wrappedPG
+	pgxPG        *wrappedPgxPG
+}
+
+func openBackend(cfg *Config, postgresURL string, tracker *tracker, observers []Observer) (*backend, error) {
+	b := &backend{
+		tracker:   tracker,
+		observers: observers,
+	}
+
+	if cfg.SlowQuery != nil {
+		b.observers = append(observers[:len(observers):len(observers)], newSlowQueryObserver(cfg, tracker, b))
+	}
+
+	if cfg.EnablePgxPoolMode {
+		pool, err := openPgxPool(cfg, postgresURL)
+		if err != nil {
+			return nil, errorz.Wrap(err, errorz.SkipPackage())
+		}
+		b.pool = pool
+		b.pgxPG = newWrappedPgxPG(pool, tracker, b.observers)
+		return b, nil
+	}
+
+	db, err := openDB(cfg, postgresURL)
+	if err != nil {
+		return nil, errorz.Wrap(err, errorz.SkipPackage())
+	}
+	b.db = db
+	b.maxIdleConns = sqlDefaultMaxIdleConns
+	if cfg.MaxIdleConns > 0 {
+		b.maxIdleConns = int(cfg.MaxIdleConns)
+	}
+	b.pg = newWrappedPG(db, tracker, b.observers)
+	return b, nil
+}
+
+// getPG returns the PG, panics if in pgx pool mode.
+func (b *backend) getPG() PG {
+	if b.pg == nil {
+		errorz.MustErrorf("PG not available in pgx pool mode", errorz.SkipPackage())
+	}
+	return b.pg
+}
+
+// getPgxPG returns the PgxPG, panics if not in pgx pool mode.
+func (b *backend) getPgxPG() PgxPG {
+	if b.pgxPG == nil {
+		errorz.MustErrorf("PgxPG only available in pgx pool mode", errorz.SkipPackage())
+	}
+	return b.pgxPG
+}
+
+func (b *backend) ping(ctx context.Context) error {
+	if b.pool != nil {
+		return errorz.MaybeWrap(b.pool.Ping(ctx), errorz.SkipPackage())
+	}
+	return errorz.MaybeWrap(b.db.PingContext(ctx), errorz.SkipPackage())
+}
+
+// Close implements the io.Closer interface.
+func (b *backend) Close() error {
+	if b.pool != nil {
+		b.pool.Close()
+		return nil
+	}
+	return errorz.MaybeWrap(b.db.Close(), errorz.SkipPackage())
+}
+
+func (b *backend) beginTx(ctx context.Context, isolationLevel sql.IsolationLevel, readOnly bool) (*backendTx, error) {
+	if b.pool != nil {
+		isoLevel, err := toPgxIsoLevel(isolationLevel)
+		if err != nil {
+			return nil, errorz.Wrap(err, errorz.SkipPackage())
+		}
+
+		accessMode := pgx.ReadWrite
+		if readOnly {
+			accessMode = pgx.ReadOnly
+		}
+
+		tx, err := b.pool.BeginTx(ctx, pgx.TxOptions{
+			IsoLevel:   isoLevel,
+			AccessMode: accessMode,
+		})
+		if err != nil {
+			return nil, errorz.Wrap(err, errorz.SkipPackage())
+		}
+		return &backendTx{pgxTx: tx, tracker: b.tracker, observers: b.observers}, nil
+	}
+
+	// The transaction runs on a dedicated *sql.Conn, so that the underlying *pgx.Conn can be reached while in progress.
+	conn, err := b.db.Conn(ctx)
+	if err != nil {
+		return nil, errorz.Wrap(err, errorz.SkipPackage())
+	}
+
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{
+		Isolation: isolationLevel,
+		ReadOnly:  readOnly,
+	})
+	if err != nil {
+		errorz.IgnoreClose(conn)
+		return nil, errorz.Wrap(err, errorz.SkipPackage())
+	}
+	return &backendTx{sqlConn: conn, sqlTx: tx, tracker: b.tracker, observers: b.observers}, nil
+}
+
+// backendTx wraps either a *sql.Tx or a pgx.Tx, depending on the backend that started it.
+type backendTx struct {
+	sqlConn   *sql.Conn
+	sqlTx     *sql.Tx
+	pgxTx     pgx.Tx
+	tracker   *tracker
+	observers []Observer
+}
+
+func (t *backendTx) inject(ctx context.Context, name string) context.Context {
+	if t.pgxTx != nil {
+		return context.WithValue(ctx, pgxContextKey.named(name), newWrappedPgxPG(t.pgxTx, t.tracker, t.observers))
+	}
+	pg := newWrappedPG(t.sqlTx, t.tracker, t.observers)
+	pg.conn = t.sqlConn
+	return context.WithValue(ctx, dbContextKey.named(name), pg)
+}
+
+func (t *backendTx) commit(ctx context.Context) error {
+	if t.pgxTx != nil {
+		return errorz.MaybeWrap(t.pgxTx.Commit(ctx), errorz.SkipPackage())
+	}
+	return errorz.MaybeWrap(t.sqlTx.Commit(), errorz.SkipPackage())
+}
+
+func (t *backendTx) rollback(ctx context.Context) error {
+	if t.pgxTx != nil {
+		return errorz.MaybeWrap(t.pgxTx.Rollback(ctx), errorz.SkipPackage())
+	}
+	defer errorz.IgnoreClose(t.sqlConn)
+	return errorz.MaybeWrap(t.sqlTx.Rollback(), errorz.SkipPackage())
+}
+
+func toPgxIsoLevel(isolationLevel sql.IsolationLevel) (pgx.TxIsoLevel, error) {
+	switch isolationLevel {
+	case sql.LevelDefault:
+		return "", nil
+	case sql.LevelReadUncommitted:
+		return pgx.ReadUncommitted, nil
+	case sql.LevelReadCommitted:
+		return pgx.ReadCommitted, nil
+	case sql.LevelRepeatableRead:
+		return pgx.RepeatableRead, nil
+	case sql.LevelSerializable:
+		return pgx.Serializable, nil
+	default:
+		return "", errorz.Errorf("unsupported isolation level: %v", errorz.A(isolationLevel), errorz.SkipPackage())
+	}
+}
+
+func openDB(cfg *Config, postgresURL string) (*sql.DB, error) {
+	connCfg, err := pgx.ParseConfig(postgresURL)
+	if err != nil {
+		return nil, errorz.Wrap(err, errorz.SkipPackage())
+	}
+	if err := configureConn(connCfg, cfg); err != nil {
+		return nil, errorz.Wrap(err, errorz.SkipPackage())
+	}
+
+	db := sql.OpenDB(&connector{
+		connCfg:       connCfg,
+		beforeConnect: newBeforeConnect(cfg),
+		afterConnect:  newAfterConnect(cfg),
+	})
+
+	if cfg.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(int(cfg.MaxOpenConns))
+	}
+	if cfg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(int(cfg.MaxIdleConns))
+	}
+	if cfg.ConnMaxLifetimeSeconds > 0 {
+		db.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetimeSeconds) * time.Second)
+	}
+	if cfg.ConnMaxIdleTimeSeconds > 0 {
+		db.SetConnMaxIdleTime(time.Duration(cfg.ConnMaxIdleTimeSeconds) * time.Second)
+	}
+
+	return db, nil
+}
+
+// connector is a driver.Connector that opens connections like the one returned by stdlib.OpenDB, but wraps them as
+// trackedConn. As the stdlib connector is not exported, connections are opened by the stdlib driver from a registered
+// copy of the pgx.ConnConfig, which also allows the before connect hook to modify it for each connection.
+type connector struct {
+	connCfg       *pgx.ConnConfig
+	beforeConnect func(context.Context, *pgx.ConnConfig) error
+	afterConnect  func(context.Context, *pgx.Conn) error
+}
+
+// Connect implements the driver.Connector interface.
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	connCfg := c.connCfg.Copy()
+
+	if c.beforeConnect != nil {
+		if err := c.beforeConnect(ctx, connCfg); err != nil {
+			return nil, errorz.Wrap(err, errorz.SkipPackage())
+		}
+	}
+
+	connStr := stdlib.RegisterConnConfig(connCfg)
+	defer stdlib.UnregisterConnConfig(connStr)
+
+	driverConnector, err := stdlib.GetDefaultDriver().(driver.DriverContext).OpenConnector(connStr)
+	if err != nil {
+		return nil, errorz.Wrap(err, errorz.SkipPackage())
+	}
+
+	driverConn, err := driverConnector.Connect(ctx)
+	if err != nil {
+		return nil, errorz.Wrap(err, errorz.SkipPackage())
+	}
+
+	conn := &trackedConn{Conn: driverConn.(*stdlib.Conn)}
+
+	if c.afterConnect != nil {
+		if err := c.afterConnect(ctx, conn.Conn.Conn()); err != nil {
+			errorz.IgnoreClose(conn)
+			return nil, errorz.Wrap(err, errorz.SkipPackage())
+		}
+	}
+
+	return conn, nil
+}
+
+// Driver implements the driver.Connector interface.
+func (c *connector) Driver() driver.Driver {
+	return stdlib.GetDefaultDriver()
+}
+
+func openPgxPool(cfg *Config, postgresURL string) (*pgxpool.Pool, error) {
+	poolCfg, err := pgxpool.ParseConfig(postgresURL)
+	if err != nil {
+		return nil, errorz.Wrap(err, errorz.SkipPackage())
+	}
+	if err := configureConn(poolCfg.ConnConfig, cfg); err != nil {
+		return nil, errorz.Wrap(err, errorz.SkipPackage())
+	}
+	poolCfg.LazyConnect = true
+
+	poolCfg.BeforeConnect = newBeforeConnect(cfg)
+	poolCfg.AfterConnect = newAfterConnect(cfg)
+
+	if cfg.MaxOpenConns > 0 {
+		poolCfg.MaxConns = int32(cfg.MaxOpenConns)
+	}
+	if cfg.ConnMaxLifetimeSeconds > 0 {
+		poolCfg.MaxConnLifetime = time.Duration(cfg.ConnMaxLifetimeSeconds) * time.Second
+	}
+	if cfg.ConnMaxIdleTimeSeconds > 0 {
+		poolCfg.MaxConnIdleTime = time.Duration(cfg.ConnMaxIdleTimeSeconds) * time.Second
+	}
+
+	pool, err := pgxpool.ConnectConfig(context.Background(), poolCfg)
+	return pool, errorz.MaybeWrap(err, errorz.SkipPackage())
+}
+
+// connect opens a dedicated connection outside of the pool, configured and hooked like pooled ones.
+func connect(ctx context.Context, cfg *Config, postgresURL string) (*pgx.Conn, error) {
+	connCfg, err := pgx.ParseConfig(postgresURL)
+	if err != nil {
+		return nil, errorz.Wrap(err, errorz.SkipPackage())
+	}
+	if err := configureConn(connCfg, cfg); err != nil {
+		return nil, errorz.Wrap(err, errorz.SkipPackage())
+	}
+
+	if beforeConnect := newBeforeConnect(cfg); beforeConnect != nil {
+		if err := beforeConnect(ctx, connCfg); err != nil {
+			return nil, errorz.Wrap(err, errorz.SkipPackage())
+		}
+	}
+
+	conn, err := pgx.ConnectConfig(ctx, connCfg)
+	if err != nil {
+		return nil, errorz.Wrap(err, errorz.SkipPackage())
+	}
+
+	if afterConnect := newAfterConnect(cfg); afterConnect != nil {
+		if err := afterConnect(ctx, conn); err != nil {
+			_ = conn.Close(ctx)
+			return nil, errorz.Wrap(err, errorz.SkipPackage())
+		}
+	}
+
+	return conn, nil
+}
+
+func configureConn(connCfg *pgx.ConnConfig, cfg *Config) error {
+	if cfg.Password != "" {
+		connCfg.Password = cfg.Password
+	}
+
+	if cfg.EnableProxyMode {
+		connCfg.BuildStatementCache = nil
+		connCfg.PreferSimpleProtocol = true
+	}
+
+	if timeout := time.Duration(cfg.ConnectTimeoutSeconds) * time.Second; timeout > 0 {
+		connCfg.ConnectTimeout = timeout
+	}
+
+	if cfg.TLS != nil {
+		if err := cfg.TLS.apply(connCfg); err != nil {
+			return errorz.Wrap(err, errorz.SkipPackage())
+		}
+	}
+
+	if cfg.Session != nil && !cfg.EnableProxyMode {
+		cfg.Session.apply(connCfg)
+	}
+
+	if cfg.TargetSessionAttrs != "" {
+		applyTargetSessionAttrs(connCfg, cfg.TargetSessionAttrs)
+	}
+
+	return nil
+}
+
+// newBeforeConnect returns the hook to be called before establishing a new connection, or nil if not needed.
+func newBeforeConnect(cfg *Config) func(context.Context, *pgx.ConnConfig) error {
+	hooks := make([]func(context.Context, *pgx.ConnConfig) error, 0)
+
+	if cfg.PasswordProvider != nil {
+		hooks = append(hooks, newCachedPasswordProvider(cfg.PasswordProvider).beforeConnect)
+	}
+
+	if cfg.TargetSessionAttrs == TargetSessionAttrsPreferStandby {
+		hooks = append(hooks, preferStandby)
+	}
+
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	return func(ctx context.Context, connCfg *pgx.ConnConfig) error {
+		for _, hook := range hooks {
+			if err := hook(ctx, connCfg); err != nil {
+				return errorz.Wrap(err, errorz.SkipPackage())
+			}
+		}
+		return nil
+	}
+}
+
+// newAfterConnect returns the hook to be called after establishing a new connection, or nil if not needed.
+func newAfterConnect(cfg *Config) func(context.Context, *pgx.Conn) error {
+	hooks := make([]func(context.Context, *pgx.Conn) error, 0)
+
+	if cfg.Session != nil && cfg.EnableProxyMode {
+		hooks = append(hooks, cfg.Session.afterConnect)
+	}
+
+	if cfg.AfterConnect != nil {
+		afterConnect := cfg.AfterConnect
+		hooks = append(hooks, func(ctx context.Context, conn *pgx.Conn) error {
+			return errorz.MaybeWrap(afterConnect(ctx, conn), errorz.Prefix("after connect"), errorz.SkipPackage())
+		})
+	}
+
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	return func(ctx context.Context, conn *pgx.Conn) error {
+		for _, hook := range hooks {
+			if err := hook(ctx, conn); err != nil {
+				return errorz.Wrap(err, errorz.SkipPackage())
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,83 @@
+package pgz
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ibrt/golang-errors/errorz"
+	"github.com/jackc/pgx/v4"
+)
+
+// Batch collects statements to be sent to the database in a single round trip.
+type Batch struct {
+	queries []string
+	batch   *pgx.Batch
+}
+
+// BatchResult describes the outcome of a statement in a Batch.
+type BatchResult struct {
+	RowsAffected int64
+	Err          error
+}
+
+// NewBatch initializes a new Batch.
+func NewBatch() *Batch {
+	return &Batch{
+		queries: make([]string, 0),
+		batch:   &pgx.Batch{},
+	}
+}
+
+// Queue queues a statement.
+func (b *Batch) Queue(query string, args ...interface{}) *Batch {
+	b.queries = append(b.queries, query)
+	b.batch.Queue(query, args...)
+	return b
+}
+
+// Len returns the number of queued statements.
+func (b *Batch) Len() int {
+	return b.batch.Len()
+}
+
+// Send sends the batch within the current transaction if any, or on a connection from the pool otherwise. It returns
+// the result of each statement, and the first error if any. As all statements run in the same transaction (implicit
+// if not in a transaction), an error also causes the following statements to fail.
+func (b *Batch) Send(ctx context.Context) ([]*BatchResult, error) {
+	return b.SendNamed(ctx, DefaultName)
+}
+
+// SendNamed is like Send, but for the named database.
+func (b *Batch) SendNamed(ctx context.Context, name string) ([]*BatchResult, error) {
+	results := make([]*BatchResult, b.Len())
+
+	if b.Len() == 0 {
+		return results, nil
+	}
+
+	err := withConn(ctx, name, strings.Join(b.queries, "; "), func(conn *pgx.Conn) error {
+		br := conn.SendBatch(ctx, b.batch)
+
+		for i := range results {
+			tag, err := br.Exec()
+			results[i] = &BatchResult{
+				RowsAffected: tag.RowsAffected(),
+				Err:          errorz.MaybeWrap(err, errorz.SkipPackage()),
+			}
+		}
+
+		return errorz.MaybeWrap(br.Close(), errorz.SkipPackage())
+	})
+
+	for _, result := range results {
+		if result != nil && result.Err != nil {
+			return results, errorz.Wrap(result.Err, errorz.SkipPackage())
+		}
+	}
+
+	if err != nil {
+		return results, errorz.Wrap(err, errorz.SkipPackage())
+	}
+
+	return results, nil
+}
@@ -0,0 +1,74 @@
+package pgz_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ibrt/golang-errors/errorz"
+	"github.com/ibrt/golang-fixtures/fixturez"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ibrt/golang-inject-pg/pgz"
+)
+
+func (s *Suite) TestBatch(ctx context.Context, t *testing.T) {
+	_, err := pgz.GetCtx(ctx).Exec(`CREATE TABLE test_batch (id bigint NOT NULL PRIMARY KEY)`)
+	fixturez.RequireNoError(t, err)
+
+	defer func() {
+		_, err := pgz.GetCtx(ctx).Exec(`DROP TABLE test_batch`)
+		fixturez.RequireNoError(t, err)
+	}()
+
+	for _, bCtx := range []context.Context{ctx, newPgxContext(ctx, t)} {
+		results, err := pgz.NewBatch().Send(bCtx)
+		fixturez.RequireNoError(t, err)
+		require.Empty(t, results)
+
+		results, err = pgz.NewBatch().
+			Queue(`INSERT INTO test_batch (id) VALUES ($1)`, 1).
+			Queue(`INSERT INTO test_batch (id) VALUES ($1), ($2)`, 2, 3).
+			Queue(`DELETE FROM test_batch WHERE id < $1`, 3).
+			Send(bCtx)
+		fixturez.RequireNoError(t, err)
+		require.Equal(t, []*pgz.BatchResult{{RowsAffected: 1}, {RowsAffected: 2}, {RowsAffected: 2}}, results)
+
+		results, err = pgz.NewBatch().
+			Queue(`INSERT INTO test_batch (id) VALUES ($1)`, 4).
+			Queue(`INSERT INTO test_batch (id) VALUES ($1)`, 3).
+			Queue(`INSERT INTO test_batch (id) VALUES ($1)`, 5).
+			Send(bCtx)
+		require.Error(t, err)
+		require.Equal(t, pgerrcode.UniqueViolation, errorz.Unwrap(err).(*pgconn.PgError).Code)
+		require.Len(t, results, 3)
+		fixturez.RequireNoError(t, results[0].Err)
+		require.Error(t, results[1].Err)
+		require.Error(t, results[2].Err)
+		require.Equal(t, []int64{3}, selectBatchIDs(ctx, t))
+
+		err = pgz.NewTx(bCtx).Run(func(tCtx context.Context) error {
+			results, err := pgz.NewBatch().
+				Queue(`INSERT INTO test_batch (id) VALUES ($1)`, 4).
+				Queue(`INSERT INTO test_batch (id) VALUES ($1)`, 5).
+				Send(tCtx)
+			fixturez.RequireNoError(t, err)
+			require.Len(t, results, 2)
+			require.Equal(t, []int64{3, 4, 5}, selectBatchIDs(tCtx, t))
+			require.Equal(t, []int64{3}, selectBatchIDs(ctx, t))
+			return errorz.Errorf("rollback")
+		})
+		require.EqualError(t, err, "rollback")
+		require.Equal(t, []int64{3}, selectBatchIDs(ctx, t))
+
+		_, err = pgz.GetCtx(ctx).Exec(`DELETE FROM test_batch`)
+		fixturez.RequireNoError(t, err)
+	}
+}
+
+func selectBatchIDs(ctx context.Context, t *testing.T) []int64 {
+	ids, err := pgz.Select[int64](ctx, `SELECT id FROM test_batch ORDER BY id`)
+	fixturez.RequireNoError(t, err)
+	return ids
+}
@@ -0,0 +1,48 @@
+package pgz
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/ibrt/golang-errors/errorz"
+	"github.com/jackc/pgx/v4"
+)
+
+// withConn calls f with the *pgx.Conn of the current transaction for the named database if any, or of a connection
+// acquired from the primary otherwise, tracking it as a query with the given description.
+func withConn(ctx context.Context, name, query string, f func(conn *pgx.Conn) error) error {
+	if pg, ok := ctx.Value(dbContextKey.named(name)).(*wrappedPG); ok && pg.isTx() {
+		defer pg.tracker.trackQuery(query)()
+		return errorz.MaybeWrap(withSQLConn(pg.conn, f), errorz.SkipPackage())
+	}
+
+	if pg, ok := ctx.Value(pgxContextKey.named(name)).(*wrappedPgxPG); ok && pg.isTx() {
+		defer pg.tracker.trackQuery(query)()
+		return errorz.MaybeWrap(f(pg.pg.(pgx.Tx).Conn()), errorz.SkipPackage())
+	}
+
+	primary := getInstance(ctx, name).primary
+	defer primary.tracker.trackQuery(query)()
+
+	if primary.pool != nil {
+		conn, err := primary.pool.Acquire(ctx)
+		if err != nil {
+			return errorz.Wrap(err, errorz.SkipPackage())
+		}
+		defer conn.Release()
+		return errorz.MaybeWrap(f(conn.Conn()), errorz.SkipPackage())
+	}
+
+	conn, err := primary.db.Conn(ctx)
+	if err != nil {
+		return errorz.Wrap(err, errorz.SkipPackage())
+	}
+	defer errorz.IgnoreClose(conn)
+	return errorz.MaybeWrap(withSQLConn(conn, f), errorz.SkipPackage())
+}
+
+func withSQLConn(conn *sql.Conn, f func(conn *pgx.Conn) error) error {
+	return errorz.MaybeWrap(conn.Raw(func(driverConn interface{}) error {
+		return f(driverConn.(*trackedConn).Conn.Conn())
+	}), errorz.SkipPackage())
+}
@@ -0,0 +1,192 @@
+package pgz
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/ibrt/golang-errors/errorz"
+	"github.com/jackc/pgx/v4"
+)
+
+// CopyFrom bulk loads rows into the given table (optionally schema qualified, e.g. "schema.table") using the COPY
+// protocol, within the current transaction if any, or on a connection from the pool otherwise. It returns the number
+// of rows copied. Sources can be built using CopyFromRows, CopyFromChannel, and CopyFromStructs.
+func CopyFrom(ctx context.Context, table string, columns []string, source pgx.CopyFromSource) (int64, error) {
+	return CopyFromNamed(ctx, DefaultName, table, columns, source)
+}
+
+// CopyFromNamed is like CopyFrom, but for the named database.
+func CopyFromNamed(ctx context.Context, name, table string, columns []string, source pgx.CopyFromSource) (int64, error) {
+	if s, ok := source.(columnsCopyFromSource); ok {
+		if err := s.setColumns(columns); err != nil {
+			return 0, errorz.Wrap(err, errorz.SkipPackage())
+		}
+	}
+
+	identifier := pgx.Identifier(strings.Split(table, "."))
+	query := getCopyQuery(identifier, columns)
+	var n int64
+
+	err := withConn(ctx, name, query, func(conn *pgx.Conn) error {
+		var err error
+		n, err = conn.CopyFrom(ctx, identifier, columns, source)
+		return errorz.MaybeWrap(err, errorz.SkipPackage())
+	})
+	if err != nil {
+		return n, errorz.Wrap(err, errorz.SkipPackage())
+	}
+
+	return n, nil
+}
+
+// getCopyQuery returns the COPY statement run by pgx for the given table and columns, e.g. to track or observe it.
+func getCopyQuery(table pgx.Identifier, columns []string) string {
+	sanitized := make([]string, len(columns))
+	for i, column := range columns {
+		sanitized[i] = pgx.Identifier{column}.Sanitize()
+	}
+	return fmt.Sprintf("COPY %v (%v) FROM STDIN", table.Sanitize(), strings.Join(sanitized, ", "))
+}
+
+// CopyFromRows returns a pgx.CopyFromSource for the given rows, each with a value for each column.
+func CopyFromRows(rows [][]interface{}) pgx.CopyFromSource {
+	return pgx.CopyFromRows(rows)
+}
+
+// CopyFromChannel returns a pgx.CopyFromSource that receives rows from the given channel until it is closed, so that
+// rows can be produced while they are being copied. A producer that fails should send its error on errs (which can be
+// nil if not needed) before closing rows, or instead of it: the error is then returned, aborting the copy. The copy is
+// also aborted if ctx is done while waiting for a row.
+func CopyFromChannel(ctx context.Context, rows <-chan []interface{}, errs <-chan error) pgx.CopyFromSource {
+	return &channelCopyFromSource{
+		ctx:  ctx,
+		rows: rows,
+		errs: errs,
+	}
+}
+
+// CopyFromStructs returns a pgx.CopyFromSource for the given structs (or pointers to structs). Columns are matched to
+// fields as for named parameters, i.e. by "db" tag, falling back to the snake case version of the field name.
+func CopyFromStructs[T any](structs []T) pgx.CopyFromSource {
+	return &structsCopyFromSource[T]{
+		structs: structs,
+		i:       -1,
+	}
+}
+
+// columnsCopyFromSource describes a pgx.CopyFromSource that needs to know the columns being copied.
+type columnsCopyFromSource interface {
+	setColumns(columns []string) error
+}
+
+type channelCopyFromSource struct {
+	ctx  context.Context
+	rows <-chan []interface{}
+	errs <-chan error
+	row  []interface{}
+	err  error
+}
+
+// Next implements the pgx.CopyFromSource interface.
+func (s *channelCopyFromSource) Next() bool {
+	for {
+		select {
+		case <-s.ctx.Done():
+			s.err = s.ctx.Err()
+			return false
+		case err, ok := <-s.errs:
+			if !ok {
+				s.errs = nil
+				continue
+			}
+			if err != nil {
+				s.err = err
+				return false
+			}
+		case row, ok := <-s.rows:
+			if !ok {
+				// An error sent right before closing rows may not have been received yet.
+				select {
+				case err := <-s.errs:
+					s.err = err
+				default:
+				}
+				return false
+			}
+			s.row = row
+			return true
+		}
+	}
+}
+
+// Values implements the pgx.CopyFromSource interface.
+func (s *channelCopyFromSource) Values() ([]interface{}, error) {
+	return s.row, nil
+}
+
+// Err implements the pgx.CopyFromSource interface.
+func (s *channelCopyFromSource) Err() error {
+	return errorz.MaybeWrap(s.err, errorz.SkipPackage())
+}
+
+type structsCopyFromSource[T any] struct {
+	structs []T
+	indexes [][]int
+	i       int
+}
+
+func (s *structsCopyFromSource[T]) setColumns(columns []string) error {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return errorz.Errorf("copy from structs requires a struct type, got %v", errorz.A(t), errorz.SkipPackage())
+	}
+
+	fields := getStructFields(t)
+	s.indexes = make([][]int, len(columns))
+
+	for i, column := range columns {
+		index, ok := fields[column]
+		if !ok {
+			return errorz.Errorf("missing struct field for column: %v", errorz.A(column), errorz.SkipPackage())
+		}
+		s.indexes[i] = index
+	}
+
+	s.i = -1
+	return nil
+}
+
+// Next implements the pgx.CopyFromSource interface.
+func (s *structsCopyFromSource[T]) Next() bool {
+	s.i++
+	return s.i < len(s.structs)
+}
+
+// Values implements the pgx.CopyFromSource interface.
+func (s *structsCopyFromSource[T]) Values() ([]interface{}, error) {
+	v := reflect.ValueOf(s.structs[s.i])
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, errorz.Errorf("nil struct at index %v", errorz.A(s.i), errorz.SkipPackage())
+		}
+		v = v.Elem()
+	}
+
+	values := make([]interface{}, len(s.indexes))
+	for i, index := range s.indexes {
+		values[i] = getStructField(v, index)
+	}
+
+	return values, nil
+}
+
+// Err implements the pgx.CopyFromSource interface.
+func (s *structsCopyFromSource[T]) Err() error {
+	return nil
+}
@@ -0,0 +1,131 @@
+package pgz_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ibrt/golang-errors/errorz"
+	"github.com/ibrt/golang-fixtures/fixturez"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ibrt/golang-inject-pg/pgz"
+)
+
+type testCopyRow struct {
+	ID      int64 `db:"id"`
+	Name    string
+	Ignored string `db:"-"`
+}
+
+func (s *Suite) TestCopyFrom(ctx context.Context, t *testing.T) {
+	_, err := pgz.GetCtx(ctx).Exec(`CREATE TABLE test_copy (id bigint NOT NULL PRIMARY KEY, name text NOT NULL)`)
+	fixturez.RequireNoError(t, err)
+
+	defer func() {
+		_, err := pgz.GetCtx(ctx).Exec(`DROP TABLE test_copy`)
+		fixturez.RequireNoError(t, err)
+	}()
+
+	columns := []string{"id", "name"}
+
+	for _, cCtx := range []context.Context{ctx, newPgxContext(ctx, t)} {
+		n, err := pgz.CopyFrom(cCtx, "test_copy", columns, pgz.CopyFromRows([][]interface{}{{1, "a"}, {2, "b"}}))
+		fixturez.RequireNoError(t, err)
+		require.Equal(t, int64(2), n)
+
+		ch := make(chan []interface{})
+		go func() {
+			defer close(ch)
+			ch <- []interface{}{3, "c"}
+			ch <- []interface{}{4, "d"}
+		}()
+
+		n, err = pgz.CopyFrom(cCtx, "public.test_copy", columns, pgz.CopyFromChannel(cCtx, ch, nil))
+		fixturez.RequireNoError(t, err)
+		require.Equal(t, int64(2), n)
+
+		ch = make(chan []interface{})
+		errs := make(chan error, 1)
+		go func() {
+			defer close(ch)
+			ch <- []interface{}{7, "g"}
+			errs <- errorz.Errorf("producer error")
+		}()
+
+		_, err = pgz.CopyFrom(cCtx, "test_copy", columns, pgz.CopyFromChannel(cCtx, ch, errs))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "producer error")
+
+		timeoutCtx, cancel := context.WithTimeout(cCtx, 200*time.Millisecond)
+		_, err = pgz.CopyFrom(timeoutCtx, "test_copy", columns, pgz.CopyFromChannel(timeoutCtx, make(chan []interface{}), nil))
+		cancel()
+		require.Error(t, err)
+		require.Len(t, selectCopyRows(ctx, t), 4)
+
+		n, err = pgz.CopyFrom(cCtx, "test_copy", columns, pgz.CopyFromStructs([]*testCopyRow{{ID: 5, Name: "e"}}))
+		fixturez.RequireNoError(t, err)
+		require.Equal(t, int64(1), n)
+		require.Equal(t, []testCopyRow{{1, "a", ""}, {2, "b", ""}, {3, "c", ""}, {4, "d", ""}, {5, "e", ""}}, selectCopyRows(ctx, t))
+
+		_, err = pgz.CopyFrom(cCtx, "test_copy", []string{"id", "ignored"}, pgz.CopyFromStructs([]testCopyRow{{ID: 6}}))
+		require.EqualError(t, err, "missing struct field for column: ignored")
+
+		_, err = pgz.CopyFrom(cCtx, "test_copy", columns, pgz.CopyFromStructs([]int{1}))
+		require.EqualError(t, err, "copy from structs requires a struct type, got int")
+
+		_, err = pgz.CopyFrom(cCtx, "test_copy", columns, pgz.CopyFromRows([][]interface{}{{6, "f"}, {1, "a"}}))
+		require.Error(t, err)
+		require.Len(t, selectCopyRows(ctx, t), 5)
+
+		err = pgz.NewTx(cCtx).Run(func(tCtx context.Context) error {
+			n, err := pgz.CopyFrom(tCtx, "test_copy", columns, pgz.CopyFromStructs([]testCopyRow{{ID: 6, Name: "f"}}))
+			fixturez.RequireNoError(t, err)
+			require.Equal(t, int64(1), n)
+			require.Len(t, selectCopyRows(tCtx, t), 6)
+			require.Len(t, selectCopyRows(ctx, t), 5)
+			return errorz.Errorf("rollback")
+		})
+		require.EqualError(t, err, "rollback")
+		require.Len(t, selectCopyRows(ctx, t), 5)
+
+		_, err = pgz.GetCtx(ctx).Exec(`DELETE FROM test_copy`)
+		fixturez.RequireNoError(t, err)
+	}
+}
+
+func TestCopyFromChannel(t *testing.T) {
+	rows := make(chan []interface{}, 2)
+	rows <- []interface{}{1}
+	close(rows)
+
+	src := pgz.CopyFromChannel(context.Background(), rows, nil)
+	require.True(t, src.Next())
+	values, err := src.Values()
+	fixturez.RequireNoError(t, err)
+	require.Equal(t, []interface{}{1}, values)
+	require.False(t, src.Next())
+	fixturez.RequireNoError(t, src.Err())
+
+	rows = make(chan []interface{}, 1)
+	errs := make(chan error, 1)
+	errs <- errorz.Errorf("producer error")
+	close(rows)
+
+	src = pgz.CopyFromChannel(context.Background(), rows, errs)
+	require.False(t, src.Next())
+	require.EqualError(t, src.Err(), "producer error")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	src = pgz.CopyFromChannel(ctx, make(chan []interface{}), nil)
+	require.False(t, src.Next())
+	require.Equal(t, context.Canceled, errorz.Unwrap(src.Err()))
+}
+
+func selectCopyRows(ctx context.Context, t *testing.T) []testCopyRow {
+	rows, err := pgz.Select[testCopyRow](ctx, `SELECT id, name FROM test_copy ORDER BY id`)
+	fixturez.RequireNoError(t, err)
+	return rows
+}
@@ -0,0 +1,60 @@
+package pgz
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ibrt/golang-errors/errorz"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	passwordExpiryMargin = 10 * time.Second
+)
+
+// PasswordProvider returns a password to be used for a new connection, and its expiration time.
+// The password is cached until shortly before its expiration time, a zero expiration time disables caching.
+type PasswordProvider func(ctx context.Context) (password string, expiresAt time.Time, err error)
+
+type cachedPasswordProvider struct {
+	provider  PasswordProvider
+	m         sync.Mutex
+	password  string
+	expiresAt time.Time
+}
+
+func newCachedPasswordProvider(provider PasswordProvider) *cachedPasswordProvider {
+	return &cachedPasswordProvider{
+		provider: provider,
+	}
+}
+
+func (p *cachedPasswordProvider) get(ctx context.Context) (string, error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if !p.expiresAt.IsZero() && time.Now().Add(passwordExpiryMargin).Before(p.expiresAt) {
+		return p.password, nil
+	}
+
+	password, expiresAt, err := p.provider(ctx)
+	if err != nil {
+		p.password, p.expiresAt = "", time.Time{}
+		return "", errorz.Wrap(err, errorz.Prefix("password provider"), errorz.SkipPackage())
+	}
+
+	p.password, p.expiresAt = password, expiresAt
+	return password, nil
+}
+
+// beforeConnect sets the password on the pgx.ConnConfig.
+func (p *cachedPasswordProvider) beforeConnect(ctx context.Context, connCfg *pgx.ConnConfig) error {
+	password, err := p.get(ctx)
+	if err != nil {
+		return errorz.Wrap(err, errorz.SkipPackage())
+	}
+
+	connCfg.Password = password
+	return nil
+}
@@ -0,0 +1,70 @@
+package pgz_test
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ibrt/golang-errors/errorz"
+	"github.com/ibrt/golang-fixtures/fixturez"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ibrt/golang-inject-pg/pgz"
+)
+
+func (s *Suite) TestPasswordProvider(ctx context.Context, t *testing.T) {
+	for _, c := range []struct {
+		expiresAt     time.Time
+		expectedCalls int64
+	}{
+		{expiresAt: time.Time{}, expectedCalls: 3},
+		{expiresAt: time.Now().Add(time.Hour), expectedCalls: 1},
+	} {
+		calls := int64(0)
+
+		pCtx, releaser := newTestContext(ctx, t, func(cfg *pgz.Config) {
+			cfg.PostgresURL = stripPassword(t, cfg.PostgresURL)
+			cfg.PasswordProvider = func(_ context.Context) (string, time.Time, error) {
+				atomic.AddInt64(&calls, 1)
+				return "password", c.expiresAt, nil
+			}
+		})
+
+		conns := make([]*sql.Conn, 0, 3)
+		for i := 0; i < 3; i++ {
+			conn, err := pgz.GetDB(pCtx).Conn(pCtx)
+			fixturez.RequireNoError(t, err)
+			conns = append(conns, conn)
+		}
+		for _, conn := range conns {
+			fixturez.RequireNoError(t, conn.Close())
+		}
+
+		releaser()
+		require.Equal(t, c.expectedCalls, atomic.LoadInt64(&calls))
+	}
+}
+
+func TestPasswordProvider_Error(t *testing.T) {
+	ctx := pgz.NewConfigSingletonInjector(&pgz.Config{
+		PostgresURL:           "postgres://postgres@localhost:3672/postgres",
+		ConnectTimeoutSeconds: 5,
+		PasswordProvider: func(_ context.Context) (string, time.Time, error) {
+			return "", time.Time{}, errorz.Errorf("test error")
+		},
+	})(context.Background())
+
+	require.PanicsWithError(t, "password provider: test error", func() {
+		pgz.Initializer(ctx)
+	})
+}
+
+func stripPassword(t *testing.T, postgresURL string) string {
+	u, err := url.Parse(postgresURL)
+	fixturez.RequireNoError(t, err)
+	u.User = url.User(u.User.Username())
+	return u.String()
+}
@@ -0,0 +1,225 @@
+package pgz
+
+import (
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ibrt/golang-errors/errorz"
+	"github.com/ibrt/golang-inject/injectz"
+	"github.com/jackc/pgservicefile"
+)
+
+var (
+	libpqEnvSettings = map[string]string{
+		"PGHOST":               "host",
+		"PGPORT":               "port",
+		"PGUSER":               "user",
+		"PGPASSWORD":           "password",
+		"PGDATABASE":           "dbname",
+		"PGSSLMODE":            "sslmode",
+		"PGSSLROOTCERT":        "sslrootcert",
+		"PGSSLCERT":            "sslcert",
+		"PGSSLKEY":             "sslkey",
+		"PGCONNECT_TIMEOUT":    "connect_timeout",
+		"PGTARGETSESSIONATTRS": "target_session_attrs",
+	}
+)
+
+// NewConfigFromEnv builds a *Config from the libpq environment variables (PGHOST, PGPORT, PGUSER, PGPASSWORD,
+// PGDATABASE, PGSSLMODE, PGSSLROOTCERT, PGSSLCERT, PGSSLKEY, PGCONNECT_TIMEOUT, PGTARGETSESSIONATTRS, PGSERVICE,
+// PGSERVICEFILE), then applies the overrides found in variables named after the given prefix (e.g. "APP_PG_PROXY_MODE"
+// for prefix "APP"). As in libpq, service file settings take precedence over the environment. The password is never
+// encoded in the PostgresURL.
+func NewConfigFromEnv(prefix string) (*Config, error) {
+	settings := make(map[string]string)
+
+	for envName, name := range libpqEnvSettings {
+		if v := os.Getenv(envName); v != "" {
+			settings[name] = v
+		}
+	}
+
+	if service := os.Getenv("PGSERVICE"); service != "" {
+		serviceSettings, err := readServiceSettings(service)
+		if err != nil {
+			return nil, errorz.Wrap(err, errorz.SkipPackage())
+		}
+		for k, v := range serviceSettings {
+			settings[k] = v
+		}
+	}
+
+	cfg := &Config{
+		PostgresURL:        buildPostgresURL(settings),
+		Password:           settings["password"],
+		TargetSessionAttrs: settings["target_session_attrs"],
+	}
+
+	if v, ok := settings["connect_timeout"]; ok {
+		connectTimeoutSeconds, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return nil, errorz.Wrap(err, errorz.Prefix("invalid connect_timeout"), errorz.SkipPackage())
+		}
+		cfg.ConnectTimeoutSeconds = uint32(connectTimeoutSeconds)
+	}
+
+	if err := applyEnvOverrides(cfg, prefix); err != nil {
+		return nil, errorz.Wrap(err, errorz.SkipPackage())
+	}
+
+	return cfg, nil
+}
+
+// NewConfigFromEnvSingletonInjector loads the *Config using NewConfigFromEnv and always injects it, panics on error.
+func NewConfigFromEnvSingletonInjector(prefix string) injectz.Injector {
+	return NewNamedConfigFromEnvSingletonInjector(DefaultName, prefix)
+}
+
+// NewNamedConfigFromEnvSingletonInjector is like NewConfigFromEnvSingletonInjector, but for the named database.
+func NewNamedConfigFromEnvSingletonInjector(name, prefix string) injectz.Injector {
+	cfg, err := NewConfigFromEnv(prefix)
+	errorz.MaybeMustWrap(err, errorz.SkipPackage())
+	return NewNamedConfigSingletonInjector(name, cfg)
+}
+
+func readServiceSettings(service string) (map[string]string, error) {
+	path := os.Getenv("PGSERVICEFILE")
+
+	if path == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, errorz.Wrap(err, errorz.SkipPackage())
+		}
+		path = filepath.Join(homeDir, ".pg_service.conf")
+	}
+
+	serviceFile, err := pgservicefile.ReadServicefile(path)
+	if err != nil {
+		return nil, errorz.Wrap(err, errorz.Prefix("failed to read service file"), errorz.SkipPackage())
+	}
+
+	s, err := serviceFile.GetService(service)
+	if err != nil {
+		return nil, errorz.Wrap(err, errorz.SkipPackage())
+	}
+
+	return s.Settings, nil
+}
+
+func buildPostgresURL(settings map[string]string) string {
+	hosts := strings.Split(getOrDefault(settings, "host", "localhost"), ",")
+	ports := strings.Split(getOrDefault(settings, "port", "5432"), ",")
+	username := getOrDefault(settings, "user", "")
+
+	if username == "" {
+		if u, err := user.Current(); err == nil {
+			username = u.Username
+		}
+	}
+
+	u := &url.URL{
+		Scheme: "postgres",
+		User:   url.User(username),
+		Path:   "/" + getOrDefault(settings, "dbname", username),
+	}
+
+	query := url.Values{}
+	hostPorts := make([]string, len(hosts))
+	portsByHost := make([]string, len(hosts))
+	hasSocket := false
+	hasIPv6 := false
+
+	for i, host := range hosts {
+		port := ports[0]
+		if i < len(ports) {
+			port = ports[i]
+		}
+
+		portsByHost[i] = port
+		hostPorts[i] = net.JoinHostPort(host, port)
+		hasSocket = hasSocket || strings.HasPrefix(host, "/")
+		hasIPv6 = hasIPv6 || strings.Contains(host, ":")
+	}
+
+	if hasSocket || (hasIPv6 && len(hosts) > 1) {
+		// Socket paths cannot be part of the URL authority, nor can IPv6 addresses if there are multiple hosts. As the
+		// "host" parameter would override the URL authority anyway, all the hosts are given as parameters instead.
+		query.Set("host", strings.Join(hosts, ","))
+		query.Set("port", strings.Join(portsByHost, ","))
+	} else {
+		u.Host = strings.Join(hostPorts, ",")
+	}
+
+	for _, k := range []string{"sslmode", "sslrootcert", "sslcert", "sslkey"} {
+		if v, ok := settings[k]; ok {
+			query.Set(k, v)
+		}
+	}
+
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func applyEnvOverrides(cfg *Config, prefix string) error {
+	envName := func(name string) string {
+		if prefix == "" {
+			return "PG_" + name
+		}
+		return prefix + "_PG_" + name
+	}
+
+	if v := os.Getenv(envName("POSTGRES_URL")); v != "" {
+		cfg.PostgresURL = v
+	}
+	if v := os.Getenv(envName("REPLICA_URLS")); v != "" {
+		cfg.ReplicaURLs = strings.Split(v, ",")
+	}
+	if v := os.Getenv(envName("TARGET_SESSION_ATTRS")); v != "" {
+		cfg.TargetSessionAttrs = v
+	}
+
+	for name, dst := range map[string]*bool{
+		"PROXY_MODE":    &cfg.EnableProxyMode,
+		"PGX_POOL_MODE": &cfg.EnablePgxPoolMode,
+		"LAZY_MODE":     &cfg.EnableLazyMode,
+	} {
+		if v := os.Getenv(envName(name)); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return errorz.Wrap(err, errorz.Prefix("invalid %v", envName(name)), errorz.SkipPackage())
+			}
+			*dst = b
+		}
+	}
+
+	for name, dst := range map[string]*uint32{
+		"CONNECT_TIMEOUT_SECONDS":    &cfg.ConnectTimeoutSeconds,
+		"MAX_OPEN_CONNS":             &cfg.MaxOpenConns,
+		"MAX_IDLE_CONNS":             &cfg.MaxIdleConns,
+		"CONN_MAX_LIFETIME_SECONDS":  &cfg.ConnMaxLifetimeSeconds,
+		"CONN_MAX_IDLE_TIME_SECONDS": &cfg.ConnMaxIdleTimeSeconds,
+		"DRAIN_TIMEOUT_SECONDS":      &cfg.DrainTimeoutSeconds,
+	} {
+		if v := os.Getenv(envName(name)); v != "" {
+			u, err := strconv.ParseUint(v, 10, 32)
+			if err != nil {
+				return errorz.Wrap(err, errorz.Prefix("invalid %v", envName(name)), errorz.SkipPackage())
+			}
+			*dst = uint32(u)
+		}
+	}
+
+	return nil
+}
+
+func getOrDefault(settings map[string]string, k, def string) string {
+	if v, ok := settings[k]; ok && v != "" {
+		return v
+	}
+	return def
+}
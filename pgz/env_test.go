@@ -0,0 +1,139 @@
+package pgz_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ibrt/golang-fixtures/fixturez"
+	"github.com/jackc/pgconn"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ibrt/golang-inject-pg/pgz"
+)
+
+func TestNewConfigFromEnv(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("PGHOST", "pg-1,pg-2")
+	t.Setenv("PGPORT", "5433")
+	t.Setenv("PGUSER", "user")
+	t.Setenv("PGPASSWORD", "p@ss/word")
+	t.Setenv("PGDATABASE", "db")
+	t.Setenv("PGSSLMODE", "require")
+	t.Setenv("PGCONNECT_TIMEOUT", "7")
+	t.Setenv("PGTARGETSESSIONATTRS", "read-write")
+	t.Setenv("APP_PG_PROXY_MODE", "true")
+	t.Setenv("APP_PG_MAX_OPEN_CONNS", "20")
+	t.Setenv("APP_PG_REPLICA_URLS", "postgres://r1/db,postgres://r2/db")
+
+	cfg, err := pgz.NewConfigFromEnv("APP")
+	fixturez.RequireNoError(t, err)
+	fixturez.RequireNoError(t, cfg.Validate())
+	require.Equal(t, &pgz.Config{
+		PostgresURL:           "postgres://user@pg-1:5433,pg-2:5433/db?sslmode=require",
+		Password:              "p@ss/word",
+		EnableProxyMode:       true,
+		ConnectTimeoutSeconds: 7,
+		MaxOpenConns:          20,
+		TargetSessionAttrs:    "read-write",
+		ReplicaURLs:           []string{"postgres://r1/db", "postgres://r2/db"},
+	}, cfg)
+
+	t.Setenv("APP_PG_MAX_OPEN_CONNS", "bad")
+	_, err = pgz.NewConfigFromEnv("APP")
+	require.EqualError(t, err, `invalid APP_PG_MAX_OPEN_CONNS: strconv.ParseUint: parsing "bad": invalid syntax`)
+}
+
+func TestNewConfigFromEnv_Socket(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("PGHOST", "/var/run/postgresql,/tmp,pg")
+	t.Setenv("PGPORT", "5432,5433,5434")
+	t.Setenv("PGUSER", "user")
+	t.Setenv("PGDATABASE", "db")
+	t.Setenv("PGSSLMODE", "disable")
+
+	cfg, err := pgz.NewConfigFromEnv("")
+	fixturez.RequireNoError(t, err)
+	fixturez.RequireNoError(t, cfg.Validate())
+	require.Equal(t, "postgres://user@/db?host=%2Fvar%2Frun%2Fpostgresql%2C%2Ftmp%2Cpg&port=5432%2C5433%2C5434&sslmode=disable", cfg.PostgresURL)
+
+	connCfg, err := pgconn.ParseConfig(cfg.PostgresURL)
+	fixturez.RequireNoError(t, err)
+	require.Equal(t, "/var/run/postgresql", connCfg.Host)
+	require.Equal(t, uint16(5432), connCfg.Port)
+	require.Len(t, connCfg.Fallbacks, 2)
+	require.Equal(t, "/tmp", connCfg.Fallbacks[0].Host)
+	require.Equal(t, uint16(5433), connCfg.Fallbacks[0].Port)
+	require.Equal(t, "pg", connCfg.Fallbacks[1].Host)
+	require.Equal(t, uint16(5434), connCfg.Fallbacks[1].Port)
+}
+
+func TestNewConfigFromEnv_IPv6(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("PGHOST", "::1")
+	t.Setenv("PGPORT", "5433")
+	t.Setenv("PGUSER", "user")
+	t.Setenv("PGDATABASE", "db")
+	t.Setenv("PGSSLMODE", "disable")
+
+	cfg, err := pgz.NewConfigFromEnv("")
+	fixturez.RequireNoError(t, err)
+	fixturez.RequireNoError(t, cfg.Validate())
+	require.Equal(t, "postgres://user@[::1]:5433/db?sslmode=disable", cfg.PostgresURL)
+
+	connCfg, err := pgconn.ParseConfig(cfg.PostgresURL)
+	fixturez.RequireNoError(t, err)
+	require.Equal(t, "::1", connCfg.Host)
+	require.Equal(t, uint16(5433), connCfg.Port)
+
+	t.Setenv("PGHOST", "::1,pg")
+	t.Setenv("PGPORT", "5433,5434")
+
+	cfg, err = pgz.NewConfigFromEnv("")
+	fixturez.RequireNoError(t, err)
+	fixturez.RequireNoError(t, cfg.Validate())
+	require.Equal(t, "postgres://user@/db?host=%3A%3A1%2Cpg&port=5433%2C5434&sslmode=disable", cfg.PostgresURL)
+
+	connCfg, err = pgconn.ParseConfig(cfg.PostgresURL)
+	fixturez.RequireNoError(t, err)
+	require.Equal(t, "::1", connCfg.Host)
+	require.Equal(t, uint16(5433), connCfg.Port)
+	require.Len(t, connCfg.Fallbacks, 1)
+	require.Equal(t, "pg", connCfg.Fallbacks[0].Host)
+	require.Equal(t, uint16(5434), connCfg.Fallbacks[0].Port)
+}
+
+func TestNewConfigFromEnv_Service(t *testing.T) {
+	clearEnv(t)
+	serviceFilePath := filepath.Join(t.TempDir(), "pg_service.conf")
+	fixturez.RequireNoError(t, os.WriteFile(serviceFilePath, []byte(
+		"[main]\nhost=pg\nport=5434\nuser=svc\npassword=secret\ndbname=svcdb\n"), 0600))
+
+	t.Setenv("PGSERVICEFILE", serviceFilePath)
+	t.Setenv("PGSERVICE", "main")
+	t.Setenv("PGHOST", "ignored")
+
+	cfg, err := pgz.NewConfigFromEnv("")
+	fixturez.RequireNoError(t, err)
+	require.Equal(t, "postgres://svc@pg:5434/svcdb", cfg.PostgresURL)
+	require.Equal(t, "secret", cfg.Password)
+
+	require.NotPanics(t, func() {
+		ctx := pgz.NewConfigFromEnvSingletonInjector("")(context.Background())
+		require.Equal(t, cfg, pgz.GetConfig(ctx))
+	})
+
+	t.Setenv("PGSERVICE", "missing")
+	_, err = pgz.NewConfigFromEnv("")
+	require.Error(t, err)
+}
+
+func clearEnv(t *testing.T) {
+	for _, k := range os.Environ() {
+		if name := k[:strings.Index(k, "=")]; strings.HasPrefix(name, "PG") || strings.Contains(name, "_PG_") {
+			t.Setenv(name, "")
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package pgz
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ibrt/golang-errors/errorz"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// Target session attributes.
+const (
+	TargetSessionAttrsAny           = "any"
+	TargetSessionAttrsReadWrite     = "read-write"
+	TargetSessionAttrsReadOnly      = "read-only"
+	TargetSessionAttrsPrimary       = "primary"
+	TargetSessionAttrsStandby       = "standby"
+	TargetSessionAttrsPreferStandby = "prefer-standby"
+)
+
+const (
+	// sqlDefaultMaxIdleConns is the database/sql default for MaxIdleConns.
+	sqlDefaultMaxIdleConns = 2
+)
+
+// applyTargetSessionAttrs sets the ValidateConnect function matching the given target session attributes, replacing
+// the one derived from the "target_session_attrs" parameter. Prefer-standby is implemented by newPreferStandby.
+func applyTargetSessionAttrs(connCfg *pgx.ConnConfig, targetSessionAttrs string) {
+	switch targetSessionAttrs {
+	case TargetSessionAttrsAny, TargetSessionAttrsPreferStandby:
+		connCfg.ValidateConnect = nil
+	case TargetSessionAttrsReadWrite:
+		connCfg.ValidateConnect = pgconn.ValidateConnectTargetSessionAttrsReadWrite
+	case TargetSessionAttrsReadOnly:
+		connCfg.ValidateConnect = pgconn.ValidateConnectTargetSessionAttrsReadOnly
+	case TargetSessionAttrsPrimary:
+		connCfg.ValidateConnect = pgconn.ValidateConnectTargetSessionAttrsPrimary
+	case TargetSessionAttrsStandby:
+		connCfg.ValidateConnect = pgconn.ValidateConnectTargetSessionAttrsStandby
+	}
+}
+
+// targetsReadWrite returns true if the primary only accepts read-write servers, i.e. if TargetSessionAttrs (or the
+// "target_session_attrs" parameter of PostgresURL) is "read-write" or "primary", so that reconnecting after a failover
+// picks the new primary.
+func targetsReadWrite(cfg *Config) bool {
+	targetSessionAttrs := cfg.TargetSessionAttrs
+	if targetSessionAttrs == "" {
+		if u, err := url.Parse(cfg.PostgresURL); err == nil {
+			targetSessionAttrs = u.Query().Get("target_session_attrs")
+		}
+	}
+	return targetSessionAttrs == TargetSessionAttrsReadWrite || targetSessionAttrs == TargetSessionAttrsPrimary
+}
+
+// preferStandby implements the "prefer-standby" target session attributes, which pgconn treats as "any": the hosts are
+// tried once requiring a standby, then once more accepting any server. Since pgconn tries the resolved hosts in order,
+// dialing exactly once per attempt, counting the dials tells which pass is in progress. It must be called on a copy of
+// the pgx.ConnConfig specific to a single connection, i.e. from a BeforeConnect hook.
+func preferStandby(ctx context.Context, connCfg *pgx.ConnConfig) error {
+	fallbacks, err := resolveFallbacks(ctx, connCfg)
+	if err != nil {
+		return errorz.Wrap(err, errorz.SkipPackage())
+	}
+	if len(fallbacks) == 0 {
+		return nil
+	}
+
+	attempts := 0
+	dialFunc := connCfg.DialFunc
+
+	connCfg.DialFunc = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		attempts++
+		return dialFunc(ctx, network, addr)
+	}
+
+	connCfg.ValidateConnect = func(ctx context.Context, pgConn *pgconn.PgConn) error {
+		if attempts <= len(fallbacks) {
+			return pgconn.ValidateConnectTargetSessionAttrsStandby(ctx, pgConn)
+		}
+		return nil
+	}
+
+	connCfg.Host = fallbacks[0].Host
+	connCfg.Port = fallbacks[0].Port
+	connCfg.TLSConfig = fallbacks[0].TLSConfig
+	connCfg.Fallbacks = append(append([]*pgconn.FallbackConfig{}, fallbacks[1:]...), fallbacks...)
+	return nil
+}
+
+// resolveFallbacks returns the host and fallbacks of the given pgx.ConnConfig, resolved to IP addresses as pgconn does,
+// so that pgconn makes exactly one attempt for each of them.
+func resolveFallbacks(ctx context.Context, connCfg *pgx.ConnConfig) ([]*pgconn.FallbackConfig, error) {
+	fallbacks := append([]*pgconn.FallbackConfig{{
+		Host:      connCfg.Host,
+		Port:      connCfg.Port,
+		TLSConfig: connCfg.TLSConfig,
+	}}, connCfg.Fallbacks...)
+
+	resolved := make([]*pgconn.FallbackConfig, 0, len(fallbacks))
+
+	for _, fallback := range fallbacks {
+		if strings.HasPrefix(fallback.Host, "/") {
+			resolved = append(resolved, fallback)
+			continue
+		}
+
+		addrs, err := connCfg.LookupFunc(ctx, fallback.Host)
+		if err != nil {
+			return nil, errorz.Wrap(err, errorz.Prefix("hostname resolving error"), errorz.SkipPackage())
+		}
+
+		for _, addr := range addrs {
+			host, port := addr, fallback.Port
+
+			if splitHost, splitPort, err := net.SplitHostPort(addr); err == nil {
+				parsedPort, err := strconv.ParseUint(splitPort, 10, 16)
+				if err != nil {
+					return nil, errorz.Wrap(err, errorz.Prefix("error parsing port from lookup"), errorz.SkipPackage())
+				}
+				host, port = splitHost, uint16(parsedPort)
+			}
+
+			resolved = append(resolved, &pgconn.FallbackConfig{
+				Host:      host,
+				Port:      port,
+				TLSConfig: fallback.TLSConfig,
+			})
+		}
+	}
+
+	return resolved, nil
+}
+
+// closeIdleConns closes the idle connections, e.g. to stop reusing connections to a demoted primary after a failover.
+func (b *backend) closeIdleConns(ctx context.Context) {
+	if b.pool != nil {
+		for _, conn := range b.pool.AcquireAllIdle(ctx) {
+			_ = conn.Conn().Close(ctx)
+			conn.Release()
+		}
+		return
+	}
+
+	b.db.SetMaxIdleConns(0)
+	b.db.SetMaxIdleConns(b.maxIdleConns)
+}
@@ -0,0 +1,176 @@
+package pgz
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ibrt/golang-errors/errorz"
+)
+
+const (
+	healthCheckTimeout = 5 * time.Second
+)
+
+// HealthReport describes the outcome of a health check.
+// The report is healthy if the primary is, unhealthy replicas are reported but do not affect the outcome.
+type HealthReport struct {
+	Healthy  bool              `json:"healthy"`
+	Primary  *DatabaseHealth   `json:"primary"`
+	Replicas []*DatabaseHealth `json:"replicas,omitempty"`
+}
+
+// DatabaseHealth describes the health of a single database.
+type DatabaseHealth struct {
+	Healthy               bool       `json:"healthy"`
+	Error                 string     `json:"error,omitempty"`
+	PingLatencyMillis     float64    `json:"pingLatencyMillis"`
+	ServerVersion         string     `json:"serverVersion,omitempty"`
+	InRecovery            bool       `json:"inRecovery"`
+	ReplicationLagSeconds *float64   `json:"replicationLagSeconds,omitempty"`
+	Pool                  *PoolStats `json:"pool"`
+}
+
+// PoolStats describes the connection pool statistics of a database.
+type PoolStats struct {
+	MaxOpenConns       int     `json:"maxOpenConns"`
+	OpenConns          int     `json:"openConns"`
+	InUseConns         int     `json:"inUseConns"`
+	IdleConns          int     `json:"idleConns"`
+	WaitCount          int64   `json:"waitCount"`
+	WaitDurationMillis float64 `json:"waitDurationMillis"`
+}
+
+// HealthCheck checks the health of the primary and replicas found in context, panics if not found.
+func HealthCheck(ctx context.Context) *HealthReport {
+	return NamedHealthCheck(ctx, DefaultName)
+}
+
+// NamedHealthCheck is like HealthCheck, but for the named database.
+func NamedHealthCheck(ctx context.Context, name string) *HealthReport {
+	inst := getInstance(ctx, name)
+
+	report := &HealthReport{
+		Replicas: make([]*DatabaseHealth, len(inst.replicas.replicas)),
+	}
+
+	// The primary and replicas are checked concurrently, each with its own timeout, so that a slow one cannot cause the
+	// others to time out.
+	wg := &sync.WaitGroup{}
+
+	check := func(b *backend, health **DatabaseHealth) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+			*health = b.checkHealth(ctx)
+		}()
+	}
+
+	check(inst.primary, &report.Primary)
+	for i, r := range inst.replicas.replicas {
+		check(r.backend, &report.Replicas[i])
+	}
+
+	wg.Wait()
+	report.Healthy = report.Primary.Healthy
+	return report
+}
+
+// NewHealthHandler returns a http.Handler that serves the HealthCheck report as JSON, with status 200 if healthy or
+// 503 if not. The request context is expected to be injected with PG (e.g. using injectz.NewMiddleware).
+func NewHealthHandler() http.Handler {
+	return NewNamedHealthHandler(DefaultName)
+}
+
+// NewNamedHealthHandler is like NewHealthHandler, but for the named database.
+func NewNamedHealthHandler(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := NamedHealthCheck(r.Context(), name)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+
+		if report.Healthy {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		_ = json.NewEncoder(w).Encode(report)
+	})
+}
+
+func (b *backend) checkHealth(ctx context.Context) *DatabaseHealth {
+	health := &DatabaseHealth{
+		Pool: b.poolStats(),
+	}
+
+	start := time.Now()
+	if err := b.ping(ctx); err != nil {
+		health.Error = err.Error()
+		return health
+	}
+	health.PingLatencyMillis = toMillis(time.Since(start))
+
+	if err := b.queryServerStatus(ctx, health); err != nil {
+		health.Error = err.Error()
+		return health
+	}
+
+	health.Healthy = true
+	return health
+}
+
+func (b *backend) queryServerStatus(ctx context.Context, health *DatabaseHealth) error {
+	const query = `
+		SELECT
+			current_setting('server_version'),
+			pg_is_in_recovery(),
+			CASE
+				WHEN NOT pg_is_in_recovery() THEN NULL
+				WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+				ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())::float8
+			END`
+
+	if b.pool != nil {
+		return errorz.MaybeWrap(b.pool.QueryRow(ctx, query).Scan(
+			&health.ServerVersion, &health.InRecovery, &health.ReplicationLagSeconds),
+			errorz.SkipPackage())
+	}
+
+	return errorz.MaybeWrap(b.db.QueryRowContext(ctx, query).Scan(
+		&health.ServerVersion, &health.InRecovery, &health.ReplicationLagSeconds),
+		errorz.SkipPackage())
+}
+
+func (b *backend) poolStats() *PoolStats {
+	if b.pool != nil {
+		stat := b.pool.Stat()
+		return &PoolStats{
+			MaxOpenConns:       int(stat.MaxConns()),
+			OpenConns:          int(stat.TotalConns()),
+			InUseConns:         int(stat.AcquiredConns()),
+			IdleConns:          int(stat.IdleConns()),
+			WaitCount:          stat.EmptyAcquireCount(),
+			WaitDurationMillis: toMillis(stat.AcquireDuration()),
+		}
+	}
+
+	stats := b.db.Stats()
+	return &PoolStats{
+		MaxOpenConns:       stats.MaxOpenConnections,
+		OpenConns:          stats.OpenConnections,
+		InUseConns:         stats.InUse,
+		IdleConns:          stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDurationMillis: toMillis(stats.WaitDuration),
+	}
+}
+
+func toMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -30,5 +30,7 @@ func (h *ConfigHelper) BeforeSuite(ctx context.Context, t *testing.T) context.Co
 			os.Getenv("POSTGRES_PORT")),
 		EnableProxyMode:       true,
 		ConnectTimeoutSeconds: 5,
+		MaxOpenConns:          10,
+		MaxIdleConns:          5,
 	})(ctx)
 }
@@ -0,0 +1,344 @@
+package pgz
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ibrt/golang-errors/errorz"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	listenerBufferSize     = 64
+	listenerPingInterval   = 30 * time.Second
+	listenerPingTimeout    = 5 * time.Second
+	listenerInitialBackoff = 100 * time.Millisecond
+	listenerMaxBackoff     = 10 * time.Second
+)
+
+// Listener receives notifications sent using NOTIFY (e.g. via Notify) on a dedicated connection to the primary, which
+// is not taken from the pool. It reconnects automatically, listening again on all channels: notifications sent while
+// disconnected are lost. Listening requires session state, so it does not work through transaction-pooling proxies.
+type Listener struct {
+	ctx     context.Context
+	name    string
+	m       sync.Mutex
+	subs    map[string][]*listenerSub
+	removed []*listenerSub
+	dirty   bool
+	wake    context.CancelFunc
+	cancel  context.CancelFunc
+	started bool
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+// listenerSub describes a subscription to a channel, which delivers notifications either on ch or by calling f.
+type listenerSub struct {
+	ch   chan *pgconn.Notification
+	f    func(n *pgconn.Notification)
+	done chan struct{}
+}
+
+// NewListener initializes a new Listener, which must be started using Start.
+func NewListener(ctx context.Context) *Listener {
+	return &Listener{
+		ctx:  ctx,
+		name: DefaultName,
+		subs: make(map[string][]*listenerSub),
+	}
+}
+
+// SetDatabaseName sets the name of the database targeted by the listener.
+func (l *Listener) SetDatabaseName(name string) *Listener {
+	l.name = name
+	return l
+}
+
+// Listen subscribes to the given channel, returning a Go channel on which notifications are delivered. The Go channel
+// is closed by Unlisten or Close. Delivery blocks the listener until received, so it should be consumed promptly.
+func (l *Listener) Listen(channel string) <-chan *pgconn.Notification {
+	ch := make(chan *pgconn.Notification, listenerBufferSize)
+	if !l.subscribe(channel, &listenerSub{ch: ch, done: make(chan struct{})}) {
+		close(ch)
+	}
+	return ch
+}
+
+// ListenFunc subscribes to the given channel, calling f for each notification. Calls are made serially from the
+// listener goroutine, so f should not block.
+func (l *Listener) ListenFunc(channel string, f func(n *pgconn.Notification)) {
+	l.subscribe(channel, &listenerSub{f: f, done: make(chan struct{})})
+}
+
+// Unlisten removes all the subscriptions to the given channel.
+func (l *Listener) Unlisten(channel string) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	for _, sub := range l.subs[channel] {
+		close(sub.done)
+		l.removed = append(l.removed, sub)
+	}
+
+	delete(l.subs, channel)
+	l.markDirty()
+
+	if !l.started {
+		l.closeRemoved()
+	}
+}
+
+// Start starts listening in background.
+func (l *Listener) Start() *Listener {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if l.started || l.closed {
+		return l
+	}
+
+	h := getHandle(l.ctx, l.name)
+	ctx, cancel := context.WithCancel(context.Background())
+	l.started = true
+	l.cancel = cancel
+
+	l.wg.Add(1)
+	go l.run(ctx, h)
+
+	return l
+}
+
+// Close stops listening and closes the Go channels returned by Listen.
+func (l *Listener) Close() error {
+	l.m.Lock()
+	if l.closed {
+		l.m.Unlock()
+		return nil
+	}
+
+	l.closed = true
+
+	if !l.started {
+		l.closeAll()
+		l.m.Unlock()
+		return nil
+	}
+
+	l.cancel()
+	l.m.Unlock()
+
+	l.wg.Wait()
+	return nil
+}
+
+func (l *Listener) subscribe(channel string, sub *listenerSub) bool {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if l.closed {
+		return false
+	}
+
+	l.subs[channel] = append(l.subs[channel], sub)
+	l.markDirty()
+	return true
+}
+
+// markDirty signals the listener goroutine that subscriptions changed, interrupting the wait for notifications.
+func (l *Listener) markDirty() {
+	l.dirty = true
+	if l.wake != nil {
+		l.wake()
+	}
+}
+
+func (l *Listener) closeRemoved() {
+	for _, sub := range l.removed {
+		if sub.ch != nil {
+			close(sub.ch)
+		}
+	}
+	l.removed = nil
+}
+
+func (l *Listener) closeAll() {
+	for channel, subs := range l.subs {
+		for _, sub := range subs {
+			close(sub.done)
+			l.removed = append(l.removed, sub)
+		}
+		delete(l.subs, channel)
+	}
+	l.closeRemoved()
+}
+
+func (l *Listener) run(ctx context.Context, h *handle) {
+	defer l.wg.Done()
+
+	defer func() {
+		l.m.Lock()
+		defer l.m.Unlock()
+		l.closeAll()
+	}()
+
+	backoff := listenerInitialBackoff
+
+	for {
+		cfg := h.get().cfg
+
+		err := l.serve(ctx, cfg, func() {
+			backoff = listenerInitialBackoff
+		})
+		if ctx.Err() != nil {
+			return
+		}
+
+		cfg.logf("pgz: listener connection failed, reconnecting in %v: %v", backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > listenerMaxBackoff {
+			backoff = listenerMaxBackoff
+		}
+	}
+}
+
+// serve connects and delivers notifications until the connection fails or ctx is done.
+func (l *Listener) serve(ctx context.Context, cfg *Config, onListening func()) error {
+	conn, err := connect(ctx, cfg, cfg.PostgresURL)
+	if err != nil {
+		return errorz.Wrap(err, errorz.SkipPackage())
+	}
+	defer func() {
+		_ = conn.Close(context.Background())
+	}()
+
+	listening := make(map[string]bool)
+
+	if err := l.sync(ctx, conn, listening); err != nil {
+		return errorz.Wrap(err, errorz.SkipPackage())
+	}
+	onListening()
+
+	for {
+		waitCtx, cancel := context.WithTimeout(ctx, listenerPingInterval)
+		l.setWake(cancel)
+
+		n, err := conn.WaitForNotification(waitCtx)
+		l.setWake(nil)
+		cancel()
+
+		if n != nil {
+			l.dispatch(ctx, n)
+		}
+
+		if err != nil {
+			if ctx.Err() != nil || waitCtx.Err() == nil {
+				return errorz.Wrap(err, errorz.SkipPackage())
+			}
+			if waitCtx.Err() == context.DeadlineExceeded {
+				if err := l.ping(ctx, conn); err != nil {
+					return errorz.Wrap(err, errorz.SkipPackage())
+				}
+			}
+		}
+
+		if err := l.sync(ctx, conn, listening); err != nil {
+			return errorz.Wrap(err, errorz.SkipPackage())
+		}
+	}
+}
+
+// ping checks that the connection is still alive after waiting for a while without notifications.
+func (l *Listener) ping(ctx context.Context, conn *pgx.Conn) error {
+	ctx, cancel := context.WithTimeout(ctx, listenerPingTimeout)
+	defer cancel()
+	return errorz.MaybeWrap(conn.Ping(ctx), errorz.SkipPackage())
+}
+
+// setWake sets the function used to interrupt the wait for notifications, calling it right away if already dirty.
+func (l *Listener) setWake(wake context.CancelFunc) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	l.wake = wake
+	if wake != nil && l.dirty {
+		wake()
+	}
+}
+
+// sync issues LISTEN and UNLISTEN statements to match the current subscriptions.
+func (l *Listener) sync(ctx context.Context, conn *pgx.Conn, listening map[string]bool) error {
+	l.m.Lock()
+	l.dirty = false
+	l.closeRemoved()
+	channels := make(map[string]bool, len(l.subs))
+	for channel := range l.subs {
+		channels[channel] = true
+	}
+	l.m.Unlock()
+
+	for channel := range channels {
+		if !listening[channel] {
+			if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+				return errorz.Wrap(err, errorz.SkipPackage())
+			}
+			listening[channel] = true
+		}
+	}
+
+	for channel := range listening {
+		if !channels[channel] {
+			if _, err := conn.Exec(ctx, "UNLISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+				return errorz.Wrap(err, errorz.SkipPackage())
+			}
+			delete(listening, channel)
+		}
+	}
+
+	return nil
+}
+
+func (l *Listener) dispatch(ctx context.Context, n *pgconn.Notification) {
+	l.m.Lock()
+	subs := append([]*listenerSub{}, l.subs[n.Channel]...)
+	l.m.Unlock()
+
+	for _, sub := range subs {
+		if sub.f != nil {
+			sub.f(n)
+			continue
+		}
+
+		select {
+		case sub.ch <- n:
+		case <-sub.done:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Notify sends a notification on the given channel using the PG (or PgxPG in pgx pool mode) from context. Within a
+// transaction, the notification is delivered on commit, and not at all on rollback.
+func Notify(ctx context.Context, channel, payload string) error {
+	return NotifyNamed(ctx, DefaultName, channel, payload)
+}
+
+// NotifyNamed is like Notify, but for the named database.
+func NotifyNamed(ctx context.Context, name, channel, payload string) error {
+	if isPgxPoolMode(ctx, name) {
+		_, err := GetNamedPgx(ctx, name).Exec(ctx, "SELECT pg_notify($1, $2)", channel, payload)
+		return errorz.MaybeWrap(err, errorz.SkipPackage())
+	}
+
+	_, err := GetNamed(ctx, name).ExecContext(ctx, "SELECT pg_notify($1, $2)", channel, payload)
+	return errorz.MaybeWrap(err, errorz.SkipPackage())
+}
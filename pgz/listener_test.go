@@ -0,0 +1,150 @@
+package pgz_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ibrt/golang-errors/errorz"
+	"github.com/ibrt/golang-fixtures/fixturez"
+	"github.com/jackc/pgconn"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ibrt/golang-inject-pg/pgz"
+)
+
+func (s *Suite) TestListener(ctx context.Context, t *testing.T) {
+	for _, nCtx := range []context.Context{ctx, newPgxContext(ctx, t)} {
+		l := pgz.NewListener(ctx)
+		ch := l.Listen("test-channel")
+		l.Start()
+
+		funcCh := make(chan *pgconn.Notification, 1)
+		l.ListenFunc("test_func", func(n *pgconn.Notification) {
+			select {
+			case funcCh <- n:
+			default:
+			}
+		})
+
+		requireListening(nCtx, t, "test-channel", ch)
+
+		fixturez.RequireNoError(t, pgz.Notify(nCtx, "test-channel", "1"))
+		require.Equal(t, "1", receiveNotification(t, ch).Payload)
+
+		err := pgz.NewTx(nCtx).Run(func(tCtx context.Context) error {
+			fixturez.RequireNoError(t, pgz.Notify(tCtx, "test-channel", "rolled-back"))
+			return errorz.Errorf("rollback")
+		})
+		require.EqualError(t, err, "rollback")
+
+		err = pgz.NewTx(nCtx).Run(func(tCtx context.Context) error {
+			return pgz.Notify(tCtx, "test-channel", "committed")
+		})
+		fixturez.RequireNoError(t, err)
+		require.Equal(t, "committed", receiveNotification(t, ch).Payload)
+
+		requireListening(nCtx, t, "test_func", funcCh)
+
+		l.Unlisten("test-channel")
+		requireClosed(t, ch)
+
+		ch = l.Listen("test-channel")
+		fixturez.RequireNoError(t, l.Close())
+		requireClosed(t, ch)
+		requireClosed(t, l.Listen("test-channel"))
+	}
+}
+
+func (s *Suite) TestListener_Reconnect(ctx context.Context, t *testing.T) {
+	l := pgz.NewListener(ctx)
+	defer func() {
+		fixturez.RequireNoError(t, l.Close())
+	}()
+
+	ch := l.Listen("test_reconnect")
+	l.Start()
+
+	requireListening(ctx, t, "test_reconnect", ch)
+
+	_, err := pgz.GetCtx(ctx).Exec(`
+		SELECT pg_terminate_backend(pid)
+		FROM pg_stat_activity
+		WHERE pid <> pg_backend_pid() AND query LIKE 'LISTEN%'`)
+	fixturez.RequireNoError(t, err)
+
+	requireListening(ctx, t, "test_reconnect", ch)
+}
+
+func (s *Suite) TestListener_NotifyNamed(ctx context.Context, t *testing.T) {
+	for _, nCtx := range []context.Context{ctx, newPgxContext(ctx, t)} {
+		nCtx = newNamedTestContext(nCtx, t, "analytics")
+
+		l := pgz.NewListener(ctx)
+		ch := l.Listen("test_named")
+		l.Start()
+
+		requireListening(ctx, t, "test_named", ch)
+
+		// The notifications are only delivered on commit if sent within the transaction on the named database.
+		err := pgz.NewTx(nCtx).SetDatabaseName("analytics").Run(func(tCtx context.Context) error {
+			fixturez.RequireNoError(t, pgz.NotifyNamed(tCtx, "analytics", "test_named", "rolled-back"))
+			return errorz.Errorf("rollback")
+		})
+		require.EqualError(t, err, "rollback")
+
+		err = pgz.NewTx(nCtx).SetDatabaseName("analytics").Run(func(tCtx context.Context) error {
+			return pgz.NotifyNamed(tCtx, "analytics", "test_named", "committed")
+		})
+		fixturez.RequireNoError(t, err)
+		require.Equal(t, "committed", receiveNotification(t, ch).Payload)
+
+		fixturez.RequireNoError(t, l.Close())
+	}
+}
+
+// requireListening sends notifications until one is received, as the listener subscribes asynchronously.
+func requireListening(ctx context.Context, t *testing.T, channel string, ch <-chan *pgconn.Notification) {
+	require.Eventually(t, func() bool {
+		if err := pgz.Notify(ctx, channel, "ping"); err != nil {
+			return false
+		}
+		select {
+		case n := <-ch:
+			return n.Channel == channel && n.Payload == "ping"
+		case <-time.After(100 * time.Millisecond):
+			return false
+		}
+	}, 10*time.Second, 10*time.Millisecond)
+}
+
+// receiveNotification receives the next notification, skipping the ones sent while waiting for the listener.
+func receiveNotification(t *testing.T, ch <-chan *pgconn.Notification) *pgconn.Notification {
+	for {
+		select {
+		case n, ok := <-ch:
+			require.True(t, ok)
+			if n.Payload != "ping" {
+				return n
+			}
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for notification")
+			return nil
+		}
+	}
+}
+
+// requireClosed requires the channel to be closed, discarding pending notifications.
+func requireClosed(t *testing.T, ch <-chan *pgconn.Notification) {
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for channel to be closed")
+			return
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package pgz
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/georgysavva/scany/dbscan"
+	"github.com/ibrt/golang-errors/errorz"
+)
+
+const (
+	namedQueryCacheSize = 1024
+)
+
+var (
+	namedQueryCache = &parsedNamedQueryCache{
+		entries: make(map[string]*parsedNamedQuery),
+	}
+	structFieldsCache = &sync.Map{}
+)
+
+// parsedNamedQuery describes a query with named parameters, translated to positional parameters.
+type parsedNamedQuery struct {
+	query string
+	names []string
+}
+
+// parsedNamedQueryCache caches parsed queries, it is reset when full to keep memory usage bounded.
+type parsedNamedQueryCache struct {
+	m       sync.RWMutex
+	entries map[string]*parsedNamedQuery
+}
+
+func (c *parsedNamedQueryCache) get(query string) *parsedNamedQuery {
+	c.m.RLock()
+	parsed, ok := c.entries[query]
+	c.m.RUnlock()
+
+	if ok {
+		return parsed
+	}
+
+	parsed = parseNamedQuery(query)
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if len(c.entries) >= namedQueryCacheSize {
+		c.entries = make(map[string]*parsedNamedQuery)
+	}
+	c.entries[query] = parsed
+
+	return parsed
+}
+
+// bindNamed translates the ":name" or "@name" parameters in the given query to positional parameters, binding them
+// from the given map[string]interface{} or struct (or pointer to struct). Struct fields are named after their "db"
+// tags, falling back to the snake case version of their names, and embedded structs are flattened.
+func bindNamed(query string, arg interface{}) (string, []interface{}, error) {
+	parsed := namedQueryCache.get(query)
+
+	values, err := getNamedValues(arg)
+	if err != nil {
+		return "", nil, errorz.Wrap(err, errorz.SkipPackage())
+	}
+
+	args := make([]interface{}, len(parsed.names))
+	for i, name := range parsed.names {
+		v, ok := values(name)
+		if !ok {
+			return "", nil, errorz.Errorf("missing named parameter: %v", errorz.A(name), errorz.SkipPackage())
+		}
+		args[i] = v
+	}
+
+	return parsed.query, args, nil
+}
+
+// parseNamedQuery parses the named parameters in the given query, skipping string literals, quoted identifiers,
+// dollar-quoted strings, comments, "::" type casts, and array slices (e.g. "arr[1:n]" or "arr[:n]").
+func parseNamedQuery(query string) *parsedNamedQuery {
+	b := &strings.Builder{}
+	names := make([]string, 0)
+	positions := make(map[string]int)
+	brackets := 0
+
+	for i := 0; i < len(query); {
+		switch c := query[i]; {
+		case c == '\'' || c == '"':
+			end := skipQuoted(query, i+1, c)
+			b.WriteString(query[i:end])
+			i = end
+		case c == '$' && (i == 0 || !isNameChar(query[i-1])):
+			end := skipDollarQuoted(query, i)
+			b.WriteString(query[i:end])
+			i = end
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			b.WriteString(query[i : i+end])
+			i += end
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				end = len(query) - i
+			} else {
+				end += 4
+			}
+			b.WriteString(query[i : i+end])
+			i += end
+		case (c == ':' || c == '@') && i+1 < len(query) && isNameStartChar(query[i+1]) && (i == 0 || query[i-1] != c) &&
+			(c == '@' || brackets == 0 || !isSliceBound(query[i-1])):
+			end := i + 1
+			for end < len(query) && isNameChar(query[end]) {
+				end++
+			}
+
+			name := query[i+1 : end]
+			position, ok := positions[name]
+			if !ok {
+				names = append(names, name)
+				position = len(names)
+				positions[name] = position
+			}
+
+			b.WriteString("$" + strconv.Itoa(position))
+			i = end
+		case c == ':' && strings.HasPrefix(query[i:], "::"):
+			b.WriteString("::")
+			i += 2
+		case c == '[' || c == ']':
+			if c == '[' {
+				brackets++
+			} else if brackets > 0 {
+				brackets--
+			}
+			b.WriteByte(c)
+			i++
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+
+	return &parsedNamedQuery{
+		query: b.String(),
+		names: names,
+	}
+}
+
+// skipQuoted returns the index after the closing quote, treating doubled quotes as escaped.
+func skipQuoted(query string, i int, quote byte) int {
+	for i < len(query) {
+		if query[i] == quote {
+			if i+1 < len(query) && query[i+1] == quote {
+				i += 2
+				continue
+			}
+			return i + 1
+		}
+		i++
+	}
+	return len(query)
+}
+
+// skipDollarQuoted returns the index after the closing tag if a dollar-quoted string starts at i, or i+1 otherwise.
+func skipDollarQuoted(query string, i int) int {
+	end := i + 1
+	for end < len(query) && query[end] != '$' {
+		if !isNameChar(query[end]) {
+			return i + 1
+		}
+		end++
+	}
+	if end >= len(query) {
+		return i + 1
+	}
+
+	tag := query[i : end+1]
+	if closing := strings.Index(query[end+1:], tag); closing >= 0 {
+		return end + 1 + closing + len(tag)
+	}
+	return len(query)
+}
+
+// isSliceBound returns true if a ":" following the given character within brackets separates array slice bounds.
+func isSliceBound(c byte) bool {
+	return c == '[' || isNameChar(c)
+}
+
+func isNameStartChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStartChar(c) || (c >= '0' && c <= '9')
+}
+
+// getNamedValues returns a lookup function for the named values in the given map or struct.
+func getNamedValues(arg interface{}) (func(string) (interface{}, bool), error) {
+	if m, ok := arg.(map[string]interface{}); ok {
+		return func(name string) (interface{}, bool) {
+			v, ok := m[name]
+			return v, ok
+		}, nil
+	}
+
+	v := reflect.ValueOf(arg)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return nil, errorz.Errorf("named parameters must be a map[string]interface{} or a struct, got %T",
+			errorz.A(arg), errorz.SkipPackage())
+	}
+
+	fields := getStructFields(v.Type())
+
+	return func(name string) (interface{}, bool) {
+		index, ok := fields[name]
+		if !ok {
+			return nil, false
+		}
+		return getStructField(v, index), true
+	}, nil
+}
+
+// getStructFields returns the index of the exported fields of the given struct type by name, using their "db" tags
+// or the snake case version of their names. Embedded structs are flattened, shallower fields taking precedence.
+func getStructFields(t reflect.Type) map[string][]int {
+	if fields, ok := structFieldsCache.Load(t); ok {
+		return fields.(map[string][]int)
+	}
+
+	fields := make(map[string][]int)
+	collectStructFields(t, nil, fields)
+	structFieldsCache.Store(t, fields)
+	return fields
+}
+
+func collectStructFields(t reflect.Type, parent []int, fields map[string][]int) {
+	embedded := make([]reflect.StructField, 0)
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, hasTag := f.Tag.Lookup("db")
+
+		if tag == "-" {
+			continue
+		}
+
+		if f.Anonymous && !hasTag {
+			if ft := f.Type; ft.Kind() == reflect.Struct || (ft.Kind() == reflect.Ptr && ft.Elem().Kind() == reflect.Struct) {
+				embedded = append(embedded, f)
+				continue
+			}
+		}
+
+		if f.PkgPath != "" {
+			continue
+		}
+
+		if tag == "" {
+			tag = dbscan.SnakeCaseMapper(f.Name)
+		}
+		if _, ok := fields[tag]; !ok {
+			fields[tag] = append(append([]int{}, parent...), i)
+		}
+	}
+
+	for _, f := range embedded {
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		embeddedFields := make(map[string][]int)
+		collectStructFields(ft, append(append([]int{}, parent...), f.Index...), embeddedFields)
+
+		for name, index := range embeddedFields {
+			if _, ok := fields[name]; !ok {
+				fields[name] = index
+			}
+		}
+	}
+}
+
+// getStructField returns the value of the field at the given index, or nil if it is within a nil embedded struct.
+func getStructField(v reflect.Value, index []int) interface{} {
+	fv, err := v.FieldByIndexErr(index)
+	if err != nil {
+		return nil
+	}
+	return fv.Interface()
+}
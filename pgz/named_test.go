@@ -0,0 +1,113 @@
+package pgz_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ibrt/golang-errors/errorz"
+	"github.com/ibrt/golang-fixtures/fixturez"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ibrt/golang-inject-pg/pgz"
+)
+
+type testNamedBase struct {
+	ID   int64  `db:"id"`
+	Name string `db:"base_name"`
+}
+
+type testNamedArg struct {
+	testNamedBase
+	Name    string
+	Ignored string `db:"-"`
+}
+
+func (s *Suite) TestNamedParameters(ctx context.Context, t *testing.T) {
+	const query = `
+		SELECT
+			:id::bigint, @name::text, ':skip' AS ":skip", $$ @skip $$, $tag$ :skip $tag$,
+			:id::bigint + 1, /* :skip */ @base_name::text -- :skip`
+
+	pgxCtx := newPgxContext(ctx, t)
+
+	for _, arg := range []interface{}{
+		map[string]interface{}{"id": int64(1), "name": "n", "base_name": "b"},
+		testNamedArg{testNamedBase: testNamedBase{ID: 1, Name: "b"}, Name: "n"},
+		&testNamedArg{testNamedBase: testNamedBase{ID: 1, Name: "b"}, Name: "n"},
+	} {
+		var (
+			id, next       int64
+			name, baseName string
+			s1, s2, s3     string
+		)
+
+		rows, err := pgz.GetCtx(ctx).NamedQuery(query, arg)
+		fixturez.RequireNoError(t, err)
+		require.True(t, rows.Next())
+		fixturez.RequireNoError(t, rows.Scan(&id, &name, &s1, &s2, &s3, &next, &baseName))
+		require.False(t, rows.Next())
+		fixturez.RequireNoError(t, rows.Err())
+		errorz.IgnoreClose(rows)
+
+		require.Equal(t, int64(1), id)
+		require.Equal(t, "n", name)
+		require.Equal(t, ":skip", s1)
+		require.Equal(t, " @skip ", s2)
+		require.Equal(t, " :skip ", s3)
+		require.Equal(t, int64(2), next)
+		require.Equal(t, "b", baseName)
+
+		pgxRows, err := pgz.GetPgxCtx(pgxCtx).NamedQuery(query, arg)
+		fixturez.RequireNoError(t, err)
+		require.True(t, pgxRows.Next())
+		fixturez.RequireNoError(t, pgxRows.Scan(&id, &name, &s1, &s2, &s3, &next, &baseName))
+		require.False(t, pgxRows.Next())
+		fixturez.RequireNoError(t, pgxRows.Err())
+		require.Equal(t, int64(2), next)
+	}
+
+	_, err := pgz.GetCtx(ctx).NamedExec(`SELECT :missing::text`, map[string]interface{}{})
+	require.EqualError(t, err, "missing named parameter: missing")
+
+	_, err = pgz.GetCtx(ctx).NamedExec(`SELECT :id::bigint`, 1)
+	require.EqualError(t, err, "named parameters must be a map[string]interface{} or a struct, got int")
+
+	res, err := pgz.GetCtx(ctx).NamedExec(`SELECT :id::bigint`, testNamedArg{})
+	fixturez.RequireNoError(t, err)
+	n, err := res.RowsAffected()
+	fixturez.RequireNoError(t, err)
+	require.Equal(t, int64(1), n)
+}
+
+func (s *Suite) TestNamedParameters_ArraySlices(ctx context.Context, t *testing.T) {
+	const query = `
+		SELECT
+			array_to_string(a[2:n], ','), array_to_string(a[:n], ','),
+			array_to_string(a[n:], ','), array_to_string(a[@from:n], ',')
+		FROM (SELECT ARRAY[10, 20, 30, 40] AS a, :n::int AS n) AS t`
+
+	arg := map[string]interface{}{"n": 3, "from": 2}
+
+	for _, nCtx := range []context.Context{ctx, newPgxContext(ctx, t)} {
+		var s1, s2, s3, s4 string
+
+		if pgz.GetConfig(nCtx).EnablePgxPoolMode {
+			rows, err := pgz.GetPgxCtx(nCtx).NamedQuery(query, arg)
+			fixturez.RequireNoError(t, err)
+			require.True(t, rows.Next())
+			fixturez.RequireNoError(t, rows.Scan(&s1, &s2, &s3, &s4))
+			rows.Close()
+		} else {
+			rows, err := pgz.GetCtx(nCtx).NamedQuery(query, arg)
+			fixturez.RequireNoError(t, err)
+			require.True(t, rows.Next())
+			fixturez.RequireNoError(t, rows.Scan(&s1, &s2, &s3, &s4))
+			errorz.IgnoreClose(rows)
+		}
+
+		require.Equal(t, "20,30", s1)
+		require.Equal(t, "10,20,30", s2)
+		require.Equal(t, "30,40", s3)
+		require.Equal(t, "20,30", s4)
+	}
+}
@@ -0,0 +1,99 @@
+package pgz
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/ibrt/golang-inject/injectz"
+	"github.com/jackc/pgconn"
+)
+
+type observersContextKey struct{}
+
+// Observer is notified of the queries run through PG and PgxPG, e.g. to log or time them. PgxPG.CopyFrom is observed.
+// The CopyFrom and CopyFromNamed functions, batches (Batch and PgxPG.SendBatch), the advisory lock helpers, and Listener
+// are not observed. The same *QueryEvent is passed to OnQueryStart and OnQueryEnd. Observers are called synchronously,
+// so they should not block.
+type Observer interface {
+	OnQueryStart(ctx context.Context, e *QueryEvent)
+	OnQueryEnd(ctx context.Context, e *QueryEvent)
+}
+
+// QueryEvent describes a query. Duration, RowsAffected, and Err are only set on end. For Query and QueryRow, the query
+// ends when the first results are available (or when scanned for QueryRow in pgx pool mode, as Err is only returned by
+// Scan), and RowsAffected is -1.
+type QueryEvent struct {
+	SQL          string
+	Args         []interface{}
+	InTx         bool
+	Start        time.Time
+	Duration     time.Duration
+	RowsAffected int64
+	Err          error
+}
+
+// NewObserverInjector injects the given Observer, which is notified of the queries run with the resulting context (or
+// derived ones), in addition to the ones configured in Config.Observers.
+func NewObserverInjector(observer Observer) injectz.Injector {
+	return func(ctx context.Context) context.Context {
+		observers := append(getContextObservers(ctx), observer)
+		return context.WithValue(ctx, observersContextKey{}, observers[:len(observers):len(observers)])
+	}
+}
+
+func getContextObservers(ctx context.Context) []Observer {
+	observers, _ := ctx.Value(observersContextKey{}).([]Observer)
+	return observers
+}
+
+// observeQuery notifies the configured and injected observers of the start of a query, returning a function that
+// notifies them of its end.
+func observeQuery(ctx context.Context, observers []Observer, inTx bool, query string, args []interface{}) func(rowsAffected int64, err error) {
+	if ctxObservers := getContextObservers(ctx); len(ctxObservers) > 0 {
+		observers = append(observers[:len(observers):len(observers)], ctxObservers...)
+	}
+
+	if len(observers) == 0 {
+		return func(int64, error) {}
+	}
+
+	e := &QueryEvent{
+		SQL:          query,
+		Args:         args,
+		InTx:         inTx,
+		Start:        time.Now(),
+		RowsAffected: -1,
+	}
+
+	for _, observer := range observers {
+		observer.OnQueryStart(ctx, e)
+	}
+
+	return func(rowsAffected int64, err error) {
+		e.Duration = time.Since(e.Start)
+		e.RowsAffected = rowsAffected
+		e.Err = err
+
+		for _, observer := range observers {
+			observer.OnQueryEnd(ctx, e)
+		}
+	}
+}
+
+func sqlRowsAffected(res sql.Result) int64 {
+	if res == nil {
+		return -1
+	}
+	if rowsAffected, err := res.RowsAffected(); err == nil {
+		return rowsAffected
+	}
+	return -1
+}
+
+func pgxRowsAffected(tag pgconn.CommandTag, err error) int64 {
+	if err != nil {
+		return -1
+	}
+	return tag.RowsAffected()
+}
@@ -0,0 +1,133 @@
+package pgz_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/ibrt/golang-fixtures/fixturez"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ibrt/golang-inject-pg/pgz"
+)
+
+type testObserver struct {
+	m       sync.Mutex
+	started []*pgz.QueryEvent
+	ended   []*pgz.QueryEvent
+}
+
+// OnQueryStart implements the pgz.Observer interface.
+func (o *testObserver) OnQueryStart(_ context.Context, e *pgz.QueryEvent) {
+	o.m.Lock()
+	defer o.m.Unlock()
+	o.started = append(o.started, e)
+}
+
+// OnQueryEnd implements the pgz.Observer interface.
+func (o *testObserver) OnQueryEnd(_ context.Context, e *pgz.QueryEvent) {
+	o.m.Lock()
+	defer o.m.Unlock()
+	o.ended = append(o.ended, e)
+}
+
+func (o *testObserver) getEvents(t *testing.T) []*pgz.QueryEvent {
+	o.m.Lock()
+	defer o.m.Unlock()
+	require.Equal(t, o.started, o.ended)
+	return o.ended
+}
+
+func (s *Suite) TestObserver(ctx context.Context, t *testing.T) {
+	for _, enablePgxPoolMode := range []bool{false, true} {
+		cfgObserver := &testObserver{}
+		oCtx, releaser := newTestContext(ctx, t, func(cfg *pgz.Config) {
+			cfg.EnablePgxPoolMode = enablePgxPoolMode
+			cfg.Observers = []pgz.Observer{cfgObserver}
+		})
+
+		ctxObserver := &testObserver{}
+		iCtx := pgz.NewObserverInjector(ctxObserver)(oCtx)
+
+		_, err := pgz.Select[int64](iCtx, `SELECT generate_series(1, $1::int)`, 3)
+		fixturez.RequireNoError(t, err)
+
+		fixturez.RequireNoError(t, pgz.ExecOne(iCtx, `SELECT 1`))
+		require.Error(t, pgz.ExecOne(iCtx, `SELECT invalid`))
+
+		err = pgz.NewTx(iCtx).Run(func(tCtx context.Context) error {
+			_, err := pgz.SelectScalar[int64](tCtx, `SELECT 1`)
+			return err
+		})
+		fixturez.RequireNoError(t, err)
+
+		fixturez.RequireNoError(t, pgz.ExecOne(oCtx, `SELECT 2`))
+
+		events := ctxObserver.getEvents(t)
+		require.Len(t, events, 4)
+
+		require.Equal(t, `SELECT generate_series(1, $1::int)`, events[0].SQL)
+		require.Equal(t, []interface{}{3}, events[0].Args)
+		require.False(t, events[0].InTx)
+		require.Equal(t, int64(-1), events[0].RowsAffected)
+		fixturez.RequireNoError(t, events[0].Err)
+		require.False(t, events[0].Start.IsZero())
+		require.Positive(t, events[0].Duration)
+
+		require.Equal(t, `SELECT 1`, events[1].SQL)
+		require.Empty(t, events[1].Args)
+		require.False(t, events[1].InTx)
+		require.Equal(t, int64(1), events[1].RowsAffected)
+		fixturez.RequireNoError(t, events[1].Err)
+
+		require.Equal(t, `SELECT invalid`, events[2].SQL)
+		require.Equal(t, int64(-1), events[2].RowsAffected)
+		require.Error(t, events[2].Err)
+
+		require.Equal(t, `SELECT 1`, events[3].SQL)
+		require.True(t, events[3].InTx)
+
+		cfgEvents := cfgObserver.getEvents(t)
+		require.Len(t, cfgEvents, 5)
+		require.Equal(t, events, cfgEvents[:4])
+		require.Equal(t, `SELECT 2`, cfgEvents[4].SQL)
+
+		if enablePgxPoolMode {
+			testPgxObserver(oCtx, t)
+		}
+
+		releaser()
+	}
+}
+
+func testPgxObserver(ctx context.Context, t *testing.T) {
+	_, err := pgz.GetPgx(ctx).Exec(ctx, `CREATE TABLE test_observer (id bigint NOT NULL PRIMARY KEY)`)
+	fixturez.RequireNoError(t, err)
+
+	defer func() {
+		_, err := pgz.GetPgx(ctx).Exec(ctx, `DROP TABLE test_observer`)
+		fixturez.RequireNoError(t, err)
+	}()
+
+	observer := &testObserver{}
+	ctx = pgz.NewObserverInjector(observer)(ctx)
+
+	var id int64
+	require.Error(t, pgz.GetPgx(ctx).QueryRow(ctx, `SELECT invalid`).Scan(&id))
+
+	n, err := pgz.GetPgx(ctx).CopyFrom(ctx, pgx.Identifier{"test_observer"}, []string{"id"}, pgx.CopyFromRows([][]interface{}{{1}, {2}}))
+	fixturez.RequireNoError(t, err)
+	require.Equal(t, int64(2), n)
+
+	events := observer.getEvents(t)
+	require.Len(t, events, 2)
+
+	require.Equal(t, `SELECT invalid`, events[0].SQL)
+	require.Equal(t, int64(-1), events[0].RowsAffected)
+	require.Error(t, events[0].Err)
+
+	require.Equal(t, `COPY "test_observer" ("id") FROM STDIN`, events[1].SQL)
+	require.Equal(t, int64(2), events[1].RowsAffected)
+	fixturez.RequireNoError(t, events[1].Err)
+}
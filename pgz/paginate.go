@@ -0,0 +1,335 @@
+package pgz
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/ibrt/golang-errors/errorz"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	// ErrIDInvalidCursor is an error ID.
+	ErrIDInvalidCursor = errorz.ID("invalid-cursor")
+)
+
+var (
+	// cursorConnInfo is used to encode cursor values. Its lookup table is built upfront as it is built lazily otherwise,
+	// which is not safe for concurrent use.
+	cursorConnInfo = func() *pgtype.ConnInfo {
+		ci := pgtype.NewConnInfo()
+		ci.DataTypeForValue(nil)
+		return ci
+	}()
+)
+
+// SortColumn describes a column used to sort and paginate results.
+type SortColumn struct {
+	Column string
+	Desc   bool
+}
+
+// Asc returns an ascending SortColumn.
+func Asc(column string) SortColumn {
+	return SortColumn{Column: column}
+}
+
+// Desc returns a descending SortColumn.
+func Desc(column string) SortColumn {
+	return SortColumn{Column: column, Desc: true}
+}
+
+// Page describes a page of results. NextCursor and PrevCursor are empty if there are no more results in that direction.
+type Page[T any] struct {
+	Rows       []T
+	NextCursor string
+	PrevCursor string
+}
+
+// pageCursor is the decoded version of a cursor token, i.e. the sort values of the row the page starts after (or ends
+// before if backward).
+type pageCursor struct {
+	Backward bool      `json:"b,omitempty"`
+	Values   []*string `json:"v"`
+}
+
+// Paginate returns the page of at most limit results of the given query following (or preceding) the one identified by
+// the given cursor token, or the first page if empty. It uses keyset pagination on the given sort columns, which must
+// be non-nullable and include a unique one (e.g. the primary key) last. Rows are scanned as in Select, and T must be a
+// struct with fields for all the sort columns. Invalid cursor tokens fail with ErrIDInvalidCursor.
+func Paginate[T any](ctx context.Context, query string, args []interface{}, sort []SortColumn, cursor string, limit int) (*Page[T], error) {
+	return PaginateNamed[T](ctx, DefaultName, query, args, sort, cursor, limit)
+}
+
+// PaginateNamed is like Paginate, but for the named database.
+func PaginateNamed[T any](ctx context.Context, name, query string, args []interface{}, sort []SortColumn, cursor string, limit int) (*Page[T], error) {
+	if len(sort) == 0 {
+		return nil, errorz.Errorf("at least one sort column is required", errorz.SkipPackage())
+	}
+	if limit <= 0 {
+		return nil, errorz.Errorf("invalid limit: %v", errorz.A(limit), errorz.SkipPackage())
+	}
+
+	fields, err := getSortFields[T](sort)
+	if err != nil {
+		return nil, errorz.Wrap(err, errorz.SkipPackage())
+	}
+
+	c, err := decodePageCursor(cursor, len(sort))
+	if err != nil {
+		return nil, errorz.Wrap(err, errorz.SkipPackage())
+	}
+
+	pageQuery, pageArgs := buildPageQuery(query, args, sort, c, limit)
+
+	rows, err := SelectNamed[T](ctx, name, pageQuery, pageArgs...)
+	if err != nil {
+		return nil, errorz.Wrap(err, errorz.SkipPackage())
+	}
+
+	hasMore := len(rows) > limit
+	if hasMore {
+		rows = rows[:limit]
+	}
+
+	backward := c != nil && c.Backward
+
+	if backward {
+		// Backward pages are fetched in reverse order.
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	page := &Page[T]{
+		Rows: rows,
+	}
+
+	if len(rows) == 0 {
+		return page, nil
+	}
+
+	if hasMore || backward {
+		if page.NextCursor, err = encodePageCursor(rows[len(rows)-1], fields, false); err != nil {
+			return nil, errorz.Wrap(err, errorz.SkipPackage())
+		}
+	}
+
+	if (c != nil && !backward) || (backward && hasMore) {
+		if page.PrevCursor, err = encodePageCursor(rows[0], fields, true); err != nil {
+			return nil, errorz.Wrap(err, errorz.SkipPackage())
+		}
+	}
+
+	return page, nil
+}
+
+// getSortFields returns the index of the struct field of T matching each sort column.
+func getSortFields[T any](sort []SortColumn) ([][]int, error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return nil, errorz.Errorf("pagination requires a struct type, got %v", errorz.A(t), errorz.SkipPackage())
+	}
+
+	structFields := getStructFields(t)
+	fields := make([][]int, len(sort))
+
+	for i, s := range sort {
+		index, ok := structFields[s.Column]
+		if !ok {
+			return nil, errorz.Errorf("missing struct field for sort column: %v", errorz.A(s.Column), errorz.SkipPackage())
+		}
+		fields[i] = index
+	}
+
+	return fields, nil
+}
+
+// buildPageQuery wraps the given query, filtering it using the cursor values, ordering, and limiting it. It fetches one
+// more row than requested to tell whether there are more results.
+func buildPageQuery(query string, args []interface{}, sort []SortColumn, c *pageCursor, limit int) (string, []interface{}) {
+	b := &strings.Builder{}
+	_, _ = fmt.Fprintf(b, "SELECT * FROM (%v) AS pgz_page", query)
+
+	pageArgs := append([]interface{}{}, args...)
+	backward := c != nil && c.Backward
+
+	if c != nil {
+		params := make([]string, len(sort))
+		for i, v := range c.Values {
+			if v != nil {
+				pageArgs = append(pageArgs, *v)
+			} else {
+				pageArgs = append(pageArgs, nil)
+			}
+			params[i] = fmt.Sprintf("$%v", len(pageArgs))
+		}
+		_, _ = fmt.Fprintf(b, " WHERE %v", buildPagePredicate(sort, params, backward))
+	}
+
+	order := make([]string, len(sort))
+	for i, s := range sort {
+		order[i] = pgx.Identifier{s.Column}.Sanitize()
+		if s.Desc != backward {
+			order[i] += " DESC"
+		}
+	}
+
+	_, _ = fmt.Fprintf(b, " ORDER BY %v LIMIT %v", strings.Join(order, ", "), limit+1)
+	return b.String(), pageArgs
+}
+
+// buildPagePredicate returns a predicate matching the rows following the cursor values in sort order (or preceding
+// them if backward). It uses a row value comparison if all columns are sorted in the same direction, so that it can
+// use a matching index, and an equivalent expanded predicate otherwise.
+func buildPagePredicate(sort []SortColumn, params []string, backward bool) string {
+	columns := make([]string, len(sort))
+	ops := make([]string, len(sort))
+	uniform := true
+
+	for i, s := range sort {
+		columns[i] = pgx.Identifier{s.Column}.Sanitize()
+		ops[i] = ">"
+		if s.Desc != backward {
+			ops[i] = "<"
+		}
+		uniform = uniform && s.Desc == sort[0].Desc
+	}
+
+	if uniform {
+		return fmt.Sprintf("(%v) %v (%v)", strings.Join(columns, ", "), ops[0], strings.Join(params, ", "))
+	}
+
+	terms := make([]string, len(sort))
+	for i := range sort {
+		conds := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			conds = append(conds, fmt.Sprintf("%v = %v", columns[j], params[j]))
+		}
+		conds = append(conds, fmt.Sprintf("%v %v %v", columns[i], ops[i], params[i]))
+		terms[i] = "(" + strings.Join(conds, " AND ") + ")"
+	}
+
+	return "(" + strings.Join(terms, " OR ") + ")"
+}
+
+func decodePageCursor(cursor string, numValues int) (*pageCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	buf, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errorz.Wrap(err, errorz.Prefix("invalid cursor"), ErrIDInvalidCursor, errorz.SkipPackage())
+	}
+
+	c := &pageCursor{}
+	if err := json.Unmarshal(buf, c); err != nil {
+		return nil, errorz.Wrap(err, errorz.Prefix("invalid cursor"), ErrIDInvalidCursor, errorz.SkipPackage())
+	}
+
+	if len(c.Values) != numValues {
+		return nil, errorz.Errorf("invalid cursor: expected %v values, got %v",
+			errorz.A(numValues, len(c.Values)), ErrIDInvalidCursor, errorz.SkipPackage())
+	}
+
+	return c, nil
+}
+
+func encodePageCursor(row interface{}, fields [][]int, backward bool) (string, error) {
+	v := reflect.ValueOf(row)
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+
+	c := &pageCursor{
+		Backward: backward,
+		Values:   make([]*string, len(fields)),
+	}
+
+	for i, index := range fields {
+		value, err := encodeCursorValue(getStructField(v, index))
+		if err != nil {
+			return "", errorz.Wrap(err, errorz.SkipPackage())
+		}
+		c.Values[i] = value
+	}
+
+	buf, err := json.Marshal(c)
+	if err != nil {
+		return "", errorz.Wrap(err, errorz.SkipPackage())
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// encodeCursorValue returns the PostgreSQL text representation of the given value, or nil if NULL. Cursor values are
+// sent back as text parameters, which the server converts to the type of the matching sort column.
+func encodeCursorValue(value interface{}) (*string, error) {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+
+	if !v.IsValid() {
+		return nil, nil
+	}
+
+	value = v.Interface()
+
+	if s, ok := value.(string); ok {
+		return &s, nil
+	}
+
+	if enc, ok := value.(pgtype.TextEncoder); ok {
+		return encodeCursorText(enc)
+	}
+
+	if dt, ok := cursorConnInfo.DataTypeForValue(value); ok {
+		pgValue := pgtype.NewValue(dt.Value)
+		if err := pgValue.Set(value); err != nil {
+			return nil, errorz.Wrap(err, errorz.SkipPackage())
+		}
+		if enc, ok := pgValue.(pgtype.TextEncoder); ok {
+			return encodeCursorText(enc)
+		}
+	}
+
+	if valuer, ok := value.(driver.Valuer); ok {
+		driverValue, err := valuer.Value()
+		if err != nil {
+			return nil, errorz.Wrap(err, errorz.SkipPackage())
+		}
+		if _, ok := driverValue.(driver.Valuer); !ok {
+			return encodeCursorValue(driverValue)
+		}
+	}
+
+	return nil, errorz.Errorf("unsupported sort column type: %T", errorz.A(value), errorz.SkipPackage())
+}
+
+func encodeCursorText(enc pgtype.TextEncoder) (*string, error) {
+	buf, err := enc.EncodeText(cursorConnInfo, nil)
+	if err != nil {
+		return nil, errorz.Wrap(err, errorz.SkipPackage())
+	}
+	if buf == nil {
+		return nil, nil
+	}
+
+	s := string(buf)
+	return &s, nil
+}
@@ -0,0 +1,160 @@
+package pgz_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ibrt/golang-errors/errorz"
+	"github.com/ibrt/golang-fixtures/fixturez"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ibrt/golang-inject-pg/pgz"
+)
+
+type testPaginateRow struct {
+	ID        int64     `db:"id"`
+	Category  string    `db:"category"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func (s *Suite) TestPaginate(ctx context.Context, t *testing.T) {
+	_, err := pgz.GetCtx(ctx).Exec(`
+		CREATE TABLE test_paginate (
+			id bigint NOT NULL PRIMARY KEY,
+			category text NOT NULL,
+			created_at timestamptz NOT NULL
+		)`)
+	fixturez.RequireNoError(t, err)
+
+	defer func() {
+		_, err := pgz.GetCtx(ctx).Exec(`DROP TABLE test_paginate`)
+		fixturez.RequireNoError(t, err)
+	}()
+
+	_, err = pgz.GetCtx(ctx).Exec(`
+		INSERT INTO test_paginate (id, category, created_at)
+		SELECT i, CASE WHEN i % 2 = 0 THEN 'even' ELSE 'odd' END, '2022-01-01T00:00:00.123456Z'::timestamptz + i * interval '1 hour'
+		FROM generate_series(1, 7) AS i`)
+	fixturez.RequireNoError(t, err)
+
+	for _, pCtx := range []context.Context{ctx, newPgxContext(ctx, t)} {
+		paginate := func(sort []pgz.SortColumn, cursor string) *pgz.Page[*testPaginateRow] {
+			page, err := pgz.Paginate[*testPaginateRow](pCtx,
+				`SELECT * FROM test_paginate WHERE id <= $1`, []interface{}{7}, sort, cursor, 3)
+			fixturez.RequireNoError(t, err)
+			return page
+		}
+
+		sort := []pgz.SortColumn{pgz.Asc("id")}
+
+		page := paginate(sort, "")
+		require.Equal(t, []int64{1, 2, 3}, getPaginateIDs(page))
+		require.Empty(t, page.PrevCursor)
+		require.NotEmpty(t, page.NextCursor)
+
+		page = paginate(sort, page.NextCursor)
+		require.Equal(t, []int64{4, 5, 6}, getPaginateIDs(page))
+		require.NotEmpty(t, page.PrevCursor)
+		require.NotEmpty(t, page.NextCursor)
+
+		page = paginate(sort, page.NextCursor)
+		require.Equal(t, []int64{7}, getPaginateIDs(page))
+		require.NotEmpty(t, page.PrevCursor)
+		require.Empty(t, page.NextCursor)
+
+		page = paginate(sort, page.PrevCursor)
+		require.Equal(t, []int64{4, 5, 6}, getPaginateIDs(page))
+		require.NotEmpty(t, page.PrevCursor)
+		require.NotEmpty(t, page.NextCursor)
+
+		page = paginate(sort, page.PrevCursor)
+		require.Equal(t, []int64{1, 2, 3}, getPaginateIDs(page))
+		require.Empty(t, page.PrevCursor)
+		require.NotEmpty(t, page.NextCursor)
+
+		sort = []pgz.SortColumn{pgz.Asc("category"), pgz.Desc("id")}
+
+		page = paginate(sort, "")
+		require.Equal(t, []int64{6, 4, 2}, getPaginateIDs(page))
+		page = paginate(sort, page.NextCursor)
+		require.Equal(t, []int64{7, 5, 3}, getPaginateIDs(page))
+		page = paginate(sort, page.NextCursor)
+		require.Equal(t, []int64{1}, getPaginateIDs(page))
+		page = paginate(sort, page.PrevCursor)
+		require.Equal(t, []int64{7, 5, 3}, getPaginateIDs(page))
+
+		sort = []pgz.SortColumn{pgz.Desc("created_at"), pgz.Desc("id")}
+
+		page = paginate(sort, "")
+		require.Equal(t, []int64{7, 6, 5}, getPaginateIDs(page))
+		page = paginate(sort, page.NextCursor)
+		require.Equal(t, []int64{4, 3, 2}, getPaginateIDs(page))
+		page = paginate(sort, page.PrevCursor)
+		require.Equal(t, []int64{7, 6, 5}, getPaginateIDs(page))
+
+		_, err := pgz.Paginate[*testPaginateRow](pCtx, `SELECT * FROM test_paginate`, nil, sort, "invalid!", 3)
+		require.Error(t, err)
+		require.Equal(t, pgz.ErrIDInvalidCursor, errorz.GetID(err))
+
+		_, err = pgz.Paginate[*testPaginateRow](pCtx, `SELECT * FROM test_paginate`, nil, []pgz.SortColumn{pgz.Asc("id")}, page.NextCursor, 3)
+		require.EqualError(t, err, "invalid cursor: expected 1 values, got 2")
+		require.Equal(t, pgz.ErrIDInvalidCursor, errorz.GetID(err))
+
+		_, err = pgz.Paginate[*testPaginateRow](pCtx, `SELECT * FROM test_paginate`, nil, []pgz.SortColumn{pgz.Asc("other")}, "", 3)
+		require.EqualError(t, err, "missing struct field for sort column: other")
+
+		_, err = pgz.Paginate[int64](pCtx, `SELECT id FROM test_paginate`, nil, []pgz.SortColumn{pgz.Asc("id")}, "", 3)
+		require.EqualError(t, err, "pagination requires a struct type, got int64")
+
+		_, err = pgz.Paginate[*testPaginateRow](pCtx, `SELECT * FROM test_paginate`, nil, nil, "", 3)
+		require.EqualError(t, err, "at least one sort column is required")
+
+		_, err = pgz.Paginate[*testPaginateRow](pCtx, `SELECT * FROM test_paginate`, nil, sort, "", 0)
+		require.EqualError(t, err, "invalid limit: 0")
+	}
+}
+
+func (s *Suite) TestPaginate_Named(ctx context.Context, t *testing.T) {
+	const createQuery = `
+		CREATE TEMPORARY TABLE test_paginate_named ON COMMIT DROP AS
+		SELECT i::bigint AS id, 'odd' AS category, now() AS created_at FROM generate_series(1, 5) AS i`
+
+	for _, enablePgxPoolMode := range []bool{false, true} {
+		nCtx, _ := newTestContext(ctx, t, func(cfg *pgz.Config) {
+			cfg.EnablePgxPoolMode = enablePgxPoolMode
+		})
+		nCtx = newNamedTestContext(nCtx, t, "analytics")
+
+		// The temporary table is only visible within the transaction on the named database.
+		err := pgz.NewTx(nCtx).SetDatabaseName("analytics").Run(func(tCtx context.Context) error {
+			var err error
+			if enablePgxPoolMode {
+				_, err = pgz.GetNamedPgxCtx(tCtx, "analytics").Exec(createQuery)
+			} else {
+				_, err = pgz.GetNamedCtx(tCtx, "analytics").Exec(createQuery)
+			}
+			fixturez.RequireNoError(t, err)
+
+			sort := []pgz.SortColumn{pgz.Asc("id")}
+
+			page, err := pgz.PaginateNamed[*testPaginateRow](tCtx, "analytics", `SELECT * FROM test_paginate_named`, nil, sort, "", 3)
+			fixturez.RequireNoError(t, err)
+			require.Equal(t, []int64{1, 2, 3}, getPaginateIDs(page))
+
+			page, err = pgz.PaginateNamed[*testPaginateRow](tCtx, "analytics", `SELECT * FROM test_paginate_named`, nil, sort, page.NextCursor, 3)
+			fixturez.RequireNoError(t, err)
+			require.Equal(t, []int64{4, 5}, getPaginateIDs(page))
+			return nil
+		})
+		fixturez.RequireNoError(t, err)
+	}
+}
+
+func getPaginateIDs(page *pgz.Page[*testPaginateRow]) []int64 {
+	ids := make([]int64, 0, len(page.Rows))
+	for _, row := range page.Rows {
+		ids = append(ids, row.ID)
+	}
+	return ids
+}
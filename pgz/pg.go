@@ -3,13 +3,11 @@ package pgz
 import (
 	"context"
 	"database/sql"
-	"time"
 
 	"github.com/ibrt/golang-errors/errorz"
 	"github.com/ibrt/golang-inject/injectz"
 	"github.com/ibrt/golang-validation/vz"
 	"github.com/jackc/pgx/v4"
-	"github.com/jackc/pgx/v4/stdlib"
 )
 
 type contextKey int
@@ -17,38 +15,140 @@ type contextKey int
 const (
 	dbContextKey contextKey = iota
 	pgConfigContextKey
+	pgxContextKey
+	handleContextKey
 )
 
+// DefaultName is the name of the database used by the functions that do not take one.
+const DefaultName = "default"
+
+type namedContextKey struct {
+	contextKey
+	name string
+}
+
+func (k contextKey) named(name string) namedContextKey {
+	return namedContextKey{
+		contextKey: k,
+		name:       name,
+	}
+}
+
 // Config describes the configuration for PG.
 type Config struct {
-	PostgresURL           string `json:"postgresUrl" validate:"required,url"`
-	EnableProxyMode       bool   `json:"proxyMode"`
+	// PostgresURL is the connection URL of the primary.
+	PostgresURL string `json:"postgresUrl" validate:"required,url"`
+	// Password, if set, overrides the one found in PostgresURL (if any).
+	Password string `json:"-"`
+	// PasswordProvider, if set, overrides both Password and the one found in PostgresURL.
+	PasswordProvider PasswordProvider `json:"-"`
+	// EnableProxyMode disables prepared statements and uses the simple protocol, e.g. for use behind PgBouncer.
+	EnableProxyMode bool `json:"proxyMode"`
+	// EnablePgxPoolMode opens a *pgxpool.Pool (see GetPgx) instead of a *sql.DB (see Get).
+	EnablePgxPoolMode bool `json:"pgxPoolMode"`
+	// EnableLazyMode prevents Initializer from connecting: the first query or transaction does, returning errors instead
+	// of panicking, StartupRetry is ignored, and replicas are checked in background.
+	EnableLazyMode bool `json:"lazyMode"`
+	// ConnectTimeoutSeconds, if set, overrides the "connect_timeout" parameter of PostgresURL.
 	ConnectTimeoutSeconds uint32 `json:"connectTimeoutSeconds"`
+	// MaxOpenConns, if set, limits the number of open connections.
+	MaxOpenConns uint32 `json:"maxOpenConns"`
+	// MaxIdleConns, if set, limits the number of idle connections. It is ignored in pgx pool mode.
+	MaxIdleConns uint32 `json:"maxIdleConns"`
+	// ConnMaxLifetimeSeconds, if set, limits how long a connection is reused.
+	ConnMaxLifetimeSeconds uint32 `json:"connMaxLifetimeSeconds"`
+	// ConnMaxIdleTimeSeconds, if set, limits how long a connection is kept idle.
+	ConnMaxIdleTimeSeconds uint32 `json:"connMaxIdleTimeSeconds"`
+	// TargetSessionAttrs, if set, overrides the "target_session_attrs" parameter of PostgresURL (which may list multiple
+	// hosts), and also supports "prefer-standby". It only applies to the primary, not to ReplicaURLs.
+	TargetSessionAttrs string `json:"targetSessionAttrs" validate:"omitempty,oneof=any read-write read-only primary standby prefer-standby"`
+	// ReplicaURLs are the connection URLs of the read replicas, used by GetReadOnly and read-only transactions.
+	ReplicaURLs []string `json:"replicaUrls" validate:"dive,required,url"`
+	// TLS, if set, overrides the "ssl*" parameters of PostgresURL and ReplicaURLs.
+	TLS *TLSConfig `json:"tls"`
+	// Session, if set, configures the session runtime parameters of every connection.
+	Session *SessionConfig `json:"session"`
+	// StartupRetry, if set, enables retrying the initial connection in Initializer.
+	StartupRetry *StartupRetryConfig `json:"startupRetry"`
+	// DrainTimeoutSeconds is how long the releaser (and Reload) waits for the active transactions and queries before
+	// closing the pool. Zero means not waiting at all: the pool is closed right away.
+	DrainTimeoutSeconds uint32 `json:"drainTimeoutSeconds"`
+	// SlowQuery, if set, enables logging the queries slower than its threshold, with their caller location.
+	SlowQuery *SlowQueryConfig `json:"slowQuery"`
+	// AfterConnect, if set, is called on every new physical connection, after the session settings are applied.
+	AfterConnect AfterConnect `json:"-"`
+	// Observers are notified of all queries, in addition to the ones injected using NewObserverInjector.
+	Observers []Observer `json:"-"`
+	// Logger, if set, receives diagnostic messages, e.g. about startup retries, drain timeouts, and slow queries.
+	Logger Logger `json:"-"`
 }
 
 // Validate implements the vz.Validator interface.
 func (c *Config) Validate() error {
-	return errorz.MaybeWrap(vz.ValidateStruct(c), errorz.SkipPackage())
+	if err := vz.ValidateStruct(c); err != nil {
+		return errorz.Wrap(err, errorz.SkipPackage())
+	}
+
+	if c.MaxOpenConns > 0 && c.MaxIdleConns > c.MaxOpenConns {
+		return vz.NewErrFailedValidation("maxIdleConns exceeds maxOpenConns", map[string]interface{}{
+			"maxIdleConns": "ltefield",
+		}, errorz.SkipPackage())
+	}
+
+	if c.TLS != nil {
+		if err := c.TLS.Validate(); err != nil {
+			return errorz.Wrap(err, errorz.SkipPackage())
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) logf(format string, args ...interface{}) {
+	if c.Logger != nil {
+		c.Logger.Printf(format, args...)
+	}
+}
+
+// AfterConnect is called on every new physical connection before it is used, e.g. to register custom types on its
+// ConnInfo or to set session state. Returning an error discards the connection.
+type AfterConnect func(ctx context.Context, conn *pgx.Conn) error
+
+// Logger describes a logger (e.g. *log.Logger).
+type Logger interface {
+	Printf(format string, args ...interface{})
 }
 
 // NewConfigSingletonInjector always inject the given *Config.
 func NewConfigSingletonInjector(cfg *Config) injectz.Injector {
+	return NewNamedConfigSingletonInjector(DefaultName, cfg)
+}
+
+// NewNamedConfigSingletonInjector always inject the given *Config for the named database.
+func NewNamedConfigSingletonInjector(name string, cfg *Config) injectz.Injector {
 	return func(ctx context.Context) context.Context {
-		return context.WithValue(ctx, pgConfigContextKey, cfg)
+		return context.WithValue(ctx, pgConfigContextKey.named(name), cfg)
 	}
 }
 
 // GetConfig extracts the *Config from context, panics if not found.
 func GetConfig(ctx context.Context) *Config {
-	return ctx.Value(pgConfigContextKey).(*Config)
+	return GetNamedConfig(ctx, DefaultName)
 }
 
-// PG describes the pg module (a subset of *sql.DB).
+// GetNamedConfig extracts the *Config for the named database from context, panics if not found.
+func GetNamedConfig(ctx context.Context, name string) *Config {
+	return ctx.Value(pgConfigContextKey.named(name)).(*Config)
+}
+
+// PG describes the pg module (a subset of *sql.DB, plus support for named parameters).
 type PG interface {
 	ContextPG
 	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
 	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
 	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error)
+	NamedQueryContext(ctx context.Context, query string, arg interface{}) (*sql.Rows, error)
 }
 
 // ContextPG describes a PG with a cached context.
@@ -56,78 +156,138 @@ type ContextPG interface {
 	Exec(query string, args ...interface{}) (sql.Result, error)
 	Query(query string, args ...interface{}) (*sql.Rows, error)
 	QueryRow(query string, args ...interface{}) *sql.Row
+	NamedExec(query string, arg interface{}) (sql.Result, error)
+	NamedQuery(query string, arg interface{}) (*sql.Rows, error)
 }
 
+// contextPGImpl looks up the PG on each call, so that it is not affected by reloads.
 type contextPGImpl struct {
-	ctx context.Context
-	pg  PG
+	ctx   context.Context
+	getPG func() PG
+}
+
+func newContextPG(ctx context.Context, getPG func() PG) *contextPGImpl {
+	getPG() // Panics early if not found.
+
+	return &contextPGImpl{
+		ctx:   ctx,
+		getPG: getPG,
+	}
 }
 
 // Exec executes a query.
 func (p *contextPGImpl) Exec(query string, args ...interface{}) (sql.Result, error) {
-	return p.pg.ExecContext(p.ctx, query, args...)
+	return p.getPG().ExecContext(p.ctx, query, args...)
 }
 
 // Query executes a query.
 func (p *contextPGImpl) Query(query string, args ...interface{}) (*sql.Rows, error) {
-	return p.pg.QueryContext(p.ctx, query, args...)
+	return p.getPG().QueryContext(p.ctx, query, args...)
 }
 
 // QueryRow executes a query.
 func (p *contextPGImpl) QueryRow(query string, args ...interface{}) *sql.Row {
-	return p.pg.QueryRowContext(p.ctx, query, args...)
+	return p.getPG().QueryRowContext(p.ctx, query, args...)
+}
+
+// NamedExec executes a query with named parameters.
+func (p *contextPGImpl) NamedExec(query string, arg interface{}) (sql.Result, error) {
+	return p.getPG().NamedExecContext(p.ctx, query, arg)
+}
+
+// NamedQuery executes a query with named parameters.
+func (p *contextPGImpl) NamedQuery(query string, arg interface{}) (*sql.Rows, error) {
+	return p.getPG().NamedQueryContext(p.ctx, query, arg)
 }
 
 // Initializer is a PG initializer.
 func Initializer(ctx context.Context) (injectz.Injector, injectz.Releaser) {
-	cfg := ctx.Value(pgConfigContextKey).(*Config)
-	errorz.MaybeMustWrap(cfg.Validate(), errorz.SkipPackage())
+	return NamedInitializer(DefaultName)(ctx)
+}
 
-	pgxCfg, err := pgx.ParseConfig(cfg.PostgresURL)
-	errorz.MaybeMustWrap(err, errorz.SkipPackage())
+// NamedInitializer returns a PG initializer for the named database.
+func NamedInitializer(name string) injectz.Initializer {
+	return func(ctx context.Context) (injectz.Injector, injectz.Releaser) {
+		inst, err := openInstance(ctx, GetNamedConfig(ctx, name))
+		errorz.MaybeMustWrap(err, errorz.SkipPackage())
+		h := newHandle(inst)
 
-	if cfg.EnableProxyMode {
-		pgxCfg.BuildStatementCache = nil
-		pgxCfg.PreferSimpleProtocol = true
-	}
+		injector := func(ctx context.Context) context.Context {
+			return context.WithValue(ctx, handleContextKey.named(name), h)
+		}
 
-	pingCTX := ctx
+		return injector, h.release
+	}
+}
 
-	if timeout := time.Duration(cfg.ConnectTimeoutSeconds) * time.Second; timeout > 0 {
-		pgxCfg.ConnectTimeout = timeout
+// Get extracts the PG from context, panics if not found. It returns the current transaction if any, and the primary
+// otherwise. The PG is a wrapper that tracks and observes queries: use GetDB to reach the underlying *sql.DB.
+func Get(ctx context.Context) PG {
+	return GetNamed(ctx, DefaultName)
+}
 
-		var cancel context.CancelFunc
-		pingCTX, cancel = context.WithTimeout(context.Background(), timeout)
-		defer cancel()
+// GetNamed extracts the PG for the named database from context, panics if not found.
+func GetNamed(ctx context.Context, name string) PG {
+	if pg, ok := ctx.Value(dbContextKey.named(name)).(*wrappedPG); ok && pg.isTx() {
+		return pg
 	}
+	return getInstance(ctx, name).primary.getPG()
+}
+
+// GetCtx extracts the PG from context and wraps it as ContextPG, panics if not found.
+func GetCtx(ctx context.Context) ContextPG {
+	return GetNamedCtx(ctx, DefaultName)
+}
 
-	db := stdlib.OpenDB(*pgxCfg)
+// GetNamedCtx is like GetNamed but wraps the PG as ContextPG.
+func GetNamedCtx(ctx context.Context, name string) ContextPG {
+	return newContextPG(ctx, func() PG {
+		return GetNamed(ctx, name)
+	})
+}
 
-	if err := db.PingContext(pingCTX); err != nil {
-		errorz.IgnoreClose(db)
-		errorz.MustWrap(err, errorz.SkipPackage())
+// GetDB extracts the *sql.DB of the primary from context, panics if not found or in pgx pool mode. Queries run on it
+// directly are neither tracked nor observed, and its value should not be retained across reloads.
+func GetDB(ctx context.Context) *sql.DB {
+	return GetNamedDB(ctx, DefaultName)
+}
+
+// GetNamedDB is like GetDB, but for the named database.
+func GetNamedDB(ctx context.Context, name string) *sql.DB {
+	primary := getInstance(ctx, name).primary
+	if primary.db == nil {
+		errorz.MustErrorf("*sql.DB not available in pgx pool mode", errorz.SkipPackage())
 	}
+	return primary.db
+}
 
-	injector := func(ctx context.Context) context.Context {
-		return context.WithValue(ctx, dbContextKey, db)
+// GetReadOnly extracts a PG suitable for read-only queries from context, panics if not found.
+// It returns the current transaction if any, otherwise a healthy replica, falling back to the primary.
+func GetReadOnly(ctx context.Context) PG {
+	return GetNamedReadOnly(ctx, DefaultName)
+}
+
+// GetNamedReadOnly is like GetReadOnly, but for the named database.
+func GetNamedReadOnly(ctx context.Context, name string) PG {
+	if pg, ok := ctx.Value(dbContextKey.named(name)).(*wrappedPG); ok && pg.isTx() {
+		return pg
 	}
 
-	releaser := func() {
-		errorz.IgnoreClose(db)
+	if r := getInstance(ctx, name).replicas.pick(); r != nil && r.pg != nil {
+		return r.pg
 	}
 
-	return injector, releaser
+	return GetNamed(ctx, name)
 }
 
-// Get extracts the PG from context, panics if not found.
-func Get(ctx context.Context) PG {
-	return ctx.Value(dbContextKey).(PG)
+// GetReadOnlyCtx is like GetReadOnly but wraps the PG as ContextPG.
+func GetReadOnlyCtx(ctx context.Context) ContextPG {
+	return GetNamedReadOnlyCtx(ctx, DefaultName)
 }
 
-// GetCtx extracts the PG from context and wraps it as ContextPG, panics if not found.
-func GetCtx(ctx context.Context) ContextPG {
-	return &contextPGImpl{
-		ctx: ctx,
-		pg:  Get(ctx),
-	}
+// GetNamedReadOnlyCtx is like GetNamedReadOnly but wraps the PG as ContextPG.
+func GetNamedReadOnlyCtx(ctx context.Context, name string) ContextPG {
+	return newContextPG(ctx, func() PG {
+		return GetNamedReadOnly(ctx, name)
+	})
 }
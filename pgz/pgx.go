@@ -0,0 +1,152 @@
+package pgz
+
+import (
+	"context"
+
+	"github.com/ibrt/golang-errors/errorz"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// PgxPG describes the pg module in pgx pool mode (a subset of *pgxpool.Pool, plus support for named parameters).
+type PgxPG interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+	NamedExec(ctx context.Context, sql string, arg interface{}) (pgconn.CommandTag, error)
+	NamedQuery(ctx context.Context, sql string, arg interface{}) (pgx.Rows, error)
+}
+
+// ContextPgxPG describes a PgxPG with a cached context.
+type ContextPgxPG interface {
+	Exec(sql string, args ...interface{}) (pgconn.CommandTag, error)
+	Query(sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(sql string, args ...interface{}) pgx.Row
+	SendBatch(b *pgx.Batch) pgx.BatchResults
+	CopyFrom(tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+	NamedExec(sql string, arg interface{}) (pgconn.CommandTag, error)
+	NamedQuery(sql string, arg interface{}) (pgx.Rows, error)
+}
+
+// contextPgxPGImpl looks up the PgxPG on each call, so that it is not affected by reloads.
+type contextPgxPGImpl struct {
+	ctx      context.Context
+	getPgxPG func() PgxPG
+}
+
+func newContextPgxPG(ctx context.Context, getPgxPG func() PgxPG) *contextPgxPGImpl {
+	getPgxPG() // Panics early if not found.
+
+	return &contextPgxPGImpl{
+		ctx:      ctx,
+		getPgxPG: getPgxPG,
+	}
+}
+
+// Exec executes a query.
+func (p *contextPgxPGImpl) Exec(sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	return p.getPgxPG().Exec(p.ctx, sql, args...)
+}
+
+// Query executes a query.
+func (p *contextPgxPGImpl) Query(sql string, args ...interface{}) (pgx.Rows, error) {
+	return p.getPgxPG().Query(p.ctx, sql, args...)
+}
+
+// QueryRow executes a query.
+func (p *contextPgxPGImpl) QueryRow(sql string, args ...interface{}) pgx.Row {
+	return p.getPgxPG().QueryRow(p.ctx, sql, args...)
+}
+
+// SendBatch sends a batch.
+func (p *contextPgxPGImpl) SendBatch(b *pgx.Batch) pgx.BatchResults {
+	return p.getPgxPG().SendBatch(p.ctx, b)
+}
+
+// CopyFrom executes a COPY FROM.
+func (p *contextPgxPGImpl) CopyFrom(tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	return p.getPgxPG().CopyFrom(p.ctx, tableName, columnNames, rowSrc)
+}
+
+// NamedExec executes a query with named parameters.
+func (p *contextPgxPGImpl) NamedExec(sql string, arg interface{}) (pgconn.CommandTag, error) {
+	return p.getPgxPG().NamedExec(p.ctx, sql, arg)
+}
+
+// NamedQuery executes a query with named parameters.
+func (p *contextPgxPGImpl) NamedQuery(sql string, arg interface{}) (pgx.Rows, error) {
+	return p.getPgxPG().NamedQuery(p.ctx, sql, arg)
+}
+
+// GetPgx extracts the PgxPG from context, panics if not found (i.e. if not in pgx pool mode). It returns the current
+// transaction if any, and the primary otherwise. The PgxPG is a wrapper: use GetPool to reach the *pgxpool.Pool.
+func GetPgx(ctx context.Context) PgxPG {
+	return GetNamedPgx(ctx, DefaultName)
+}
+
+// GetNamedPgx is like GetPgx, but for the named database.
+func GetNamedPgx(ctx context.Context, name string) PgxPG {
+	if pg, ok := ctx.Value(pgxContextKey.named(name)).(*wrappedPgxPG); ok && pg.isTx() {
+		return pg
+	}
+	return getInstance(ctx, name).primary.getPgxPG()
+}
+
+// GetPgxCtx extracts the PgxPG from context and wraps it as ContextPgxPG, panics if not found.
+func GetPgxCtx(ctx context.Context) ContextPgxPG {
+	return GetNamedPgxCtx(ctx, DefaultName)
+}
+
+// GetNamedPgxCtx is like GetNamedPgx but wraps the PgxPG as ContextPgxPG.
+func GetNamedPgxCtx(ctx context.Context, name string) ContextPgxPG {
+	return newContextPgxPG(ctx, func() PgxPG {
+		return GetNamedPgx(ctx, name)
+	})
+}
+
+// GetPool is like GetDB, but returns the *pgxpool.Pool of the primary, panics if not in pgx pool mode.
+func GetPool(ctx context.Context) *pgxpool.Pool {
+	return GetNamedPool(ctx, DefaultName)
+}
+
+// GetNamedPool is like GetPool, but for the named database.
+func GetNamedPool(ctx context.Context, name string) *pgxpool.Pool {
+	primary := getInstance(ctx, name).primary
+	if primary.pool == nil {
+		errorz.MustErrorf("*pgxpool.Pool only available in pgx pool mode", errorz.SkipPackage())
+	}
+	return primary.pool
+}
+
+// GetPgxReadOnly is like GetReadOnly, but for pgx pool mode.
+func GetPgxReadOnly(ctx context.Context) PgxPG {
+	return GetNamedPgxReadOnly(ctx, DefaultName)
+}
+
+// GetNamedPgxReadOnly is like GetPgxReadOnly, but for the named database.
+func GetNamedPgxReadOnly(ctx context.Context, name string) PgxPG {
+	if pg, ok := ctx.Value(pgxContextKey.named(name)).(*wrappedPgxPG); ok && pg.isTx() {
+		return pg
+	}
+
+	if r := getInstance(ctx, name).replicas.pick(); r != nil && r.pgxPG != nil {
+		return r.pgxPG
+	}
+
+	return GetNamedPgx(ctx, name)
+}
+
+// GetPgxReadOnlyCtx is like GetPgxReadOnly but wraps the PgxPG as ContextPgxPG.
+func GetPgxReadOnlyCtx(ctx context.Context) ContextPgxPG {
+	return GetNamedPgxReadOnlyCtx(ctx, DefaultName)
+}
+
+// GetNamedPgxReadOnlyCtx is like GetNamedPgxReadOnly but wraps the PgxPG as ContextPgxPG.
+func GetNamedPgxReadOnlyCtx(ctx context.Context, name string) ContextPgxPG {
+	return newContextPgxPG(ctx, func() PgxPG {
+		return GetNamedPgxReadOnly(ctx, name)
+	})
+}
@@ -0,0 +1,149 @@
+package pgz_test
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+
+	"github.com/ibrt/golang-errors/errorz"
+	"github.com/ibrt/golang-fixtures/fixturez"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ibrt/golang-inject-pg/pgz"
+)
+
+func (s *Suite) TestPgx(ctx context.Context, t *testing.T) {
+	ctx = newPgxContext(ctx, t)
+
+	require.Panics(t, func() {
+		pgz.Get(ctx)
+	})
+	require.Panics(t, func() {
+		pgz.GetDB(ctx)
+	})
+	require.Equal(t, int32(10), pgz.GetPool(ctx).Stat().MaxConns())
+
+	_, err := pgz.GetPgx(ctx).Exec(ctx, `CREATE TABLE test_pgx (id bigint NOT NULL PRIMARY KEY, vals bigint[] NOT NULL)`)
+	fixturez.RequireNoError(t, err)
+	defer func() {
+		_, err := pgz.GetPgxCtx(ctx).Exec(`DROP TABLE test_pgx`)
+		fixturez.RequireNoError(t, err)
+	}()
+
+	n, err := pgz.GetPgxCtx(ctx).CopyFrom(
+		pgx.Identifier{"test_pgx"},
+		[]string{"id", "vals"},
+		pgx.CopyFromRows([][]interface{}{{1, []int64{1, 2}}, {2, []int64{3}}}))
+	fixturez.RequireNoError(t, err)
+	require.EqualValues(t, 2, n)
+
+	b := &pgx.Batch{}
+	b.Queue(`UPDATE test_pgx SET vals = array_append(vals, 4) WHERE id = 2`)
+	b.Queue(`SELECT vals FROM test_pgx WHERE id = 2`)
+	br := pgz.GetPgxCtx(ctx).SendBatch(b)
+	_, err = br.Exec()
+	fixturez.RequireNoError(t, err)
+	var values []int64
+	fixturez.RequireNoError(t, br.QueryRow().Scan(&values))
+	fixturez.RequireNoError(t, br.Close())
+	require.Equal(t, []int64{3, 4}, values)
+
+	rows, err := pgz.GetPgxCtx(ctx).Query(`SELECT id FROM test_pgx ORDER BY id`)
+	fixturez.RequireNoError(t, err)
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		fixturez.RequireNoError(t, rows.Scan(&id))
+		ids = append(ids, id)
+	}
+	fixturez.RequireNoError(t, rows.Err())
+	require.Equal(t, []int64{1, 2}, ids)
+}
+
+func (s *Suite) TestPgx_Transaction(ctx context.Context, t *testing.T) {
+	ctx = newPgxContext(ctx, t)
+
+	_, err := pgz.GetPgxCtx(ctx).Exec(`CREATE TABLE test_pgx_tx (id bigint NOT NULL PRIMARY KEY)`)
+	fixturez.RequireNoError(t, err)
+	defer func() {
+		_, err := pgz.GetPgxCtx(ctx).Exec(`DROP TABLE test_pgx_tx`)
+		fixturez.RequireNoError(t, err)
+	}()
+
+	err = pgz.NewTx(ctx).SetIsolationLevel(sql.LevelSerializable).Run(func(ctx context.Context) error {
+		if _, err := pgz.GetPgxCtx(ctx).Exec(`INSERT INTO test_pgx_tx (id) VALUES (1)`); err != nil {
+			return errorz.Wrap(err)
+		}
+
+		return pgz.NewTx(ctx).SetAllowReentrant(false).Run(func(ctx context.Context) error {
+			return nil
+		})
+	})
+	require.EqualError(t, err, "unexpectedly nested transaction")
+
+	err = pgz.NewTx(ctx).SetReadOnly(true).Run(func(ctx context.Context) error {
+		_, err := pgz.GetPgxCtx(ctx).Exec(`INSERT INTO test_pgx_tx (id) VALUES (1)`)
+		return errorz.MaybeWrap(err)
+	})
+	require.Error(t, err)
+	require.Equal(t, pgerrcode.ReadOnlySQLTransaction, errorz.Unwrap(err).(*pgconn.PgError).Code)
+
+	err = pgz.NewTx(ctx).Run(func(ctx context.Context) error {
+		_, err := pgz.GetPgxCtx(ctx).Exec(`INSERT INTO test_pgx_tx (id) VALUES (1)`)
+		return errorz.MaybeWrap(err)
+	})
+	fixturez.RequireNoError(t, err)
+
+	var count int64
+	fixturez.RequireNoError(t, pgz.GetPgxReadOnlyCtx(ctx).QueryRow(`SELECT count(*) FROM test_pgx_tx`).Scan(&count))
+	require.EqualValues(t, 1, count)
+
+	err = pgz.NewTx(ctx).SetIsolationLevel(sql.LevelLinearizable).Run(func(ctx context.Context) error {
+		return nil
+	})
+	require.EqualError(t, err, "unsupported isolation level: Linearizable")
+}
+
+func newPgxContext(ctx context.Context, t *testing.T) context.Context {
+	pgxCtx, _ := newTestContext(ctx, t, func(cfg *pgz.Config) {
+		cfg.EnablePgxPoolMode = true
+	})
+	return pgxCtx
+}
+
+// newConfigContext returns a new context with a copy of the *Config found in ctx, modified by configure (if not nil).
+func newConfigContext(ctx context.Context, configure func(cfg *pgz.Config)) context.Context {
+	cfg := *pgz.GetConfig(ctx)
+	if configure != nil {
+		configure(&cfg)
+	}
+	return pgz.NewConfigSingletonInjector(&cfg)(context.Background())
+}
+
+// newTestContext is like newConfigContext, but also initializes PG. The returned releaser can be called more than once,
+// and is called on cleanup.
+func newTestContext(ctx context.Context, t *testing.T, configure func(cfg *pgz.Config)) (context.Context, func()) {
+	cCtx := newConfigContext(ctx, configure)
+	injector, releaser := pgz.Initializer(cCtx)
+
+	once := &sync.Once{}
+	release := func() {
+		once.Do(releaser)
+	}
+
+	t.Cleanup(release)
+	return injector(cCtx), release
+}
+
+// newNamedTestContext initializes the named database in ctx, with a copy of the *Config found in ctx.
+func newNamedTestContext(ctx context.Context, t *testing.T, name string) context.Context {
+	cfg := *pgz.GetConfig(ctx)
+	nCtx := pgz.NewNamedConfigSingletonInjector(name, &cfg)(ctx)
+	injector, releaser := pgz.NamedInitializer(name)(nCtx)
+	t.Cleanup(releaser)
+	return injector(nCtx)
+}
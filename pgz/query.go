@@ -0,0 +1,173 @@
+package pgz
+
+import (
+	"context"
+
+	"github.com/georgysavva/scany/dbscan"
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/georgysavva/scany/sqlscan"
+	"github.com/ibrt/golang-errors/errorz"
+)
+
+const (
+	// ErrIDNotFound is an error ID.
+	ErrIDNotFound = errorz.ID("not-found")
+)
+
+// Select runs a query using the PG (or PgxPG in pgx pool mode) from context, i.e. within the current transaction if
+// any, scanning the rows into a []T. Structs are scanned using "db" tags, other types require a single column.
+func Select[T any](ctx context.Context, query string, args ...interface{}) ([]T, error) {
+	return SelectNamed[T](ctx, DefaultName, query, args...)
+}
+
+// SelectNamed is like Select, but for the named database.
+func SelectNamed[T any](ctx context.Context, name, query string, args ...interface{}) ([]T, error) {
+	dst := make([]T, 0)
+
+	if isPgxPoolMode(ctx, name) {
+		if err := pgxscan.Select(ctx, GetNamedPgx(ctx, name), &dst, query, args...); err != nil {
+			return nil, errorz.Wrap(err, errorz.SkipPackage())
+		}
+		return dst, nil
+	}
+
+	if err := sqlscan.Select(ctx, GetNamed(ctx, name), &dst, query, args...); err != nil {
+		return nil, errorz.Wrap(err, errorz.SkipPackage())
+	}
+	return dst, nil
+}
+
+// SelectOne is like Select, but expects exactly one row. It fails with ErrIDNotFound if no rows are returned.
+func SelectOne[T any](ctx context.Context, query string, args ...interface{}) (T, error) {
+	return SelectOneNamed[T](ctx, DefaultName, query, args...)
+}
+
+// SelectOneNamed is like SelectOne, but for the named database.
+func SelectOneNamed[T any](ctx context.Context, name, query string, args ...interface{}) (T, error) {
+	var dst T
+
+	var err error
+	if isPgxPoolMode(ctx, name) {
+		err = pgxscan.Get(ctx, GetNamedPgx(ctx, name), &dst, query, args...)
+	} else {
+		err = sqlscan.Get(ctx, GetNamed(ctx, name), &dst, query, args...)
+	}
+
+	if err != nil {
+		var zero T
+		if dbscan.NotFound(err) {
+			return zero, errorz.Errorf("no rows found", ErrIDNotFound, errorz.SkipPackage())
+		}
+		return zero, errorz.Wrap(err, errorz.SkipPackage())
+	}
+
+	return dst, nil
+}
+
+// SelectScalar is like SelectOne, but expects a single column, scanned directly into a T (e.g. a time.Time).
+func SelectScalar[T any](ctx context.Context, query string, args ...interface{}) (T, error) {
+	return SelectScalarNamed[T](ctx, DefaultName, query, args...)
+}
+
+// SelectScalarNamed is like SelectScalar, but for the named database.
+func SelectScalarNamed[T any](ctx context.Context, name, query string, args ...interface{}) (T, error) {
+	var dst T
+	var zero T
+
+	if isPgxPoolMode(ctx, name) {
+		rows, err := GetNamedPgx(ctx, name).Query(ctx, query, args...)
+		if err != nil {
+			return zero, errorz.Wrap(err, errorz.SkipPackage())
+		}
+		defer rows.Close()
+
+		if err := scanScalar(rows, &dst); err != nil {
+			return zero, errorz.Wrap(err, errorz.SkipPackage())
+		}
+		return dst, nil
+	}
+
+	rows, err := GetNamed(ctx, name).QueryContext(ctx, query, args...)
+	if err != nil {
+		return zero, errorz.Wrap(err, errorz.SkipPackage())
+	}
+	defer errorz.IgnoreClose(rows)
+
+	if err := scanScalar(rows, &dst); err != nil {
+		return zero, errorz.Wrap(err, errorz.SkipPackage())
+	}
+	return dst, nil
+}
+
+// ExecOne executes a query using the PG (or PgxPG in pgx pool mode) from context, expecting it to affect exactly one
+// row. It fails with ErrIDNotFound if no rows are affected.
+func ExecOne(ctx context.Context, query string, args ...interface{}) error {
+	return ExecOneNamed(ctx, DefaultName, query, args...)
+}
+
+// ExecOneNamed is like ExecOne, but for the named database.
+func ExecOneNamed(ctx context.Context, name, query string, args ...interface{}) error {
+	var rowsAffected int64
+
+	if isPgxPoolMode(ctx, name) {
+		tag, err := GetNamedPgx(ctx, name).Exec(ctx, query, args...)
+		if err != nil {
+			return errorz.Wrap(err, errorz.SkipPackage())
+		}
+		rowsAffected = tag.RowsAffected()
+	} else {
+		res, err := GetNamed(ctx, name).ExecContext(ctx, query, args...)
+		if err != nil {
+			return errorz.Wrap(err, errorz.SkipPackage())
+		}
+		if rowsAffected, err = res.RowsAffected(); err != nil {
+			return errorz.Wrap(err, errorz.SkipPackage())
+		}
+	}
+
+	switch rowsAffected {
+	case 0:
+		return errorz.Errorf("no rows affected", ErrIDNotFound, errorz.SkipPackage())
+	case 1:
+		return nil
+	default:
+		return errorz.Errorf("expected 1 affected row, got %v", errorz.A(rowsAffected), errorz.SkipPackage())
+	}
+}
+
+// scannableRows describes the common subset of *sql.Rows and pgx.Rows.
+type scannableRows interface {
+	Next() bool
+	Scan(dest ...interface{}) error
+	Err() error
+}
+
+func scanScalar(rows scannableRows, dst interface{}) error {
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return errorz.Wrap(err, errorz.SkipPackage())
+		}
+		return errorz.Errorf("no rows found", ErrIDNotFound, errorz.SkipPackage())
+	}
+
+	if err := rows.Scan(dst); err != nil {
+		return errorz.Wrap(err, errorz.SkipPackage())
+	}
+
+	if rows.Next() {
+		return errorz.Errorf("expected 1 row, got more", errorz.SkipPackage())
+	}
+
+	return errorz.MaybeWrap(rows.Err(), errorz.SkipPackage())
+}
+
+// isPgxPoolMode returns true if the named database found in context is in pgx pool mode.
+func isPgxPoolMode(ctx context.Context, name string) bool {
+	if _, ok := ctx.Value(pgxContextKey.named(name)).(*wrappedPgxPG); ok {
+		return true
+	}
+	if _, ok := ctx.Value(dbContextKey.named(name)).(*wrappedPG); ok {
+		return false
+	}
+	return getInstance(ctx, name).primary.pool != nil
+}
@@ -0,0 +1,110 @@
+package pgz_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ibrt/golang-errors/errorz"
+	"github.com/ibrt/golang-fixtures/fixturez"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ibrt/golang-inject-pg/pgz"
+)
+
+type testQueryRow struct {
+	ID   int64  `db:"id"`
+	Name string `db:"name"`
+}
+
+func (s *Suite) TestQuery(ctx context.Context, t *testing.T) {
+	_, err := pgz.GetCtx(ctx).Exec(`CREATE TABLE test_query (id bigint NOT NULL PRIMARY KEY, n bigint NOT NULL)`)
+	fixturez.RequireNoError(t, err)
+	_, err = pgz.GetCtx(ctx).Exec(`INSERT INTO test_query (id, n) VALUES (1, 0), (2, 0)`)
+	fixturez.RequireNoError(t, err)
+
+	defer func() {
+		_, err := pgz.GetCtx(ctx).Exec(`DROP TABLE test_query`)
+		fixturez.RequireNoError(t, err)
+	}()
+
+	for _, qCtx := range []context.Context{ctx, newPgxContext(ctx, t)} {
+		testQuery(qCtx, t)
+
+		err := pgz.NewTx(qCtx).Run(func(ctx context.Context) error {
+			testQuery(ctx, t)
+			return nil
+		})
+		fixturez.RequireNoError(t, err)
+	}
+}
+
+func (s *Suite) TestQuery_Named(ctx context.Context, t *testing.T) {
+	nCtx := newNamedTestContext(ctx, t, "analytics")
+
+	const readOnlyQuery = `SELECT current_setting('transaction_read_only') = 'on'`
+
+	err := pgz.NewTx(nCtx).SetDatabaseName("analytics").SetReadOnly(true).Run(func(ctx context.Context) error {
+		readOnly, err := pgz.SelectScalarNamed[bool](ctx, "analytics", readOnlyQuery)
+		fixturez.RequireNoError(t, err)
+		require.True(t, readOnly)
+
+		readOnly, err = pgz.SelectScalar[bool](ctx, readOnlyQuery)
+		fixturez.RequireNoError(t, err)
+		require.False(t, readOnly)
+
+		values, err := pgz.SelectNamed[bool](ctx, "analytics", readOnlyQuery)
+		fixturez.RequireNoError(t, err)
+		require.Equal(t, []bool{true}, values)
+
+		readOnly, err = pgz.SelectOneNamed[bool](ctx, "analytics", readOnlyQuery)
+		fixturez.RequireNoError(t, err)
+		require.True(t, readOnly)
+
+		return pgz.ExecOneNamed(ctx, "analytics", readOnlyQuery)
+	})
+	fixturez.RequireNoError(t, err)
+}
+
+func testQuery(ctx context.Context, t *testing.T) {
+	rows, err := pgz.Select[*testQueryRow](ctx, `SELECT * FROM (VALUES (1, 'a'), (2, 'b')) AS t (id, name) ORDER BY id`)
+	fixturez.RequireNoError(t, err)
+	require.Equal(t, []*testQueryRow{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}}, rows)
+
+	rows, err = pgz.Select[*testQueryRow](ctx, `SELECT 1 AS id, 'a' AS name WHERE false`)
+	fixturez.RequireNoError(t, err)
+	require.Empty(t, rows)
+
+	ids, err := pgz.Select[int64](ctx, `SELECT generate_series(1, 3)`)
+	fixturez.RequireNoError(t, err)
+	require.Equal(t, []int64{1, 2, 3}, ids)
+
+	row, err := pgz.SelectOne[testQueryRow](ctx, `SELECT 1 AS id, 'a' AS name`)
+	fixturez.RequireNoError(t, err)
+	require.Equal(t, testQueryRow{ID: 1, Name: "a"}, row)
+
+	_, err = pgz.SelectOne[testQueryRow](ctx, `SELECT 1 AS id, 'a' AS name WHERE false`)
+	require.EqualError(t, err, "no rows found")
+	require.Equal(t, pgz.ErrIDNotFound, errorz.GetID(err))
+
+	_, err = pgz.SelectOne[testQueryRow](ctx, `SELECT generate_series(1, 2) AS id, 'a' AS name`)
+	require.Error(t, err)
+
+	now, err := pgz.SelectScalar[time.Time](ctx, `SELECT '2022-01-01T00:00:00Z'::timestamptz`)
+	fixturez.RequireNoError(t, err)
+	require.True(t, time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC).Equal(now))
+
+	_, err = pgz.SelectScalar[int64](ctx, `SELECT 1 WHERE false`)
+	require.Equal(t, pgz.ErrIDNotFound, errorz.GetID(err))
+
+	_, err = pgz.SelectScalar[int64](ctx, `SELECT generate_series(1, 2)`)
+	require.EqualError(t, err, "expected 1 row, got more")
+
+	fixturez.RequireNoError(t, pgz.ExecOne(ctx, `UPDATE test_query SET n = n + 1 WHERE id = 1`))
+
+	err = pgz.ExecOne(ctx, `UPDATE test_query SET n = n + 1 WHERE id = 0`)
+	require.EqualError(t, err, "no rows affected")
+	require.Equal(t, pgz.ErrIDNotFound, errorz.GetID(err))
+
+	require.EqualError(t, pgz.ExecOne(ctx, `UPDATE test_query SET n = n + 1`), "expected 1 affected row, got 2")
+}
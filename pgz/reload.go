@@ -0,0 +1,156 @@
+package pgz
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ibrt/golang-errors/errorz"
+)
+
+// instance describes the primary and replicas opened from a given *Config.
+type instance struct {
+	cfg              *Config
+	tracker          *tracker
+	primary          *backend
+	primaryReadWrite bool
+	replicas         *replicaSet
+}
+
+func openInstance(ctx context.Context, cfg *Config) (*instance, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, errorz.Wrap(err, errorz.SkipPackage())
+	}
+
+	tracker := newTracker()
+
+	primary, err := openBackend(cfg, cfg.PostgresURL, tracker, cfg.Observers)
+	if err != nil {
+		return nil, errorz.Wrap(err, errorz.SkipPackage())
+	}
+
+	if !cfg.EnableLazyMode {
+		if err := pingWithRetry(ctx, cfg, primary); err != nil {
+			errorz.IgnoreClose(primary)
+			return nil, errorz.Wrap(err, errorz.SkipPackage())
+		}
+	}
+
+	replicas, err := openReplicaSet(cfg, tracker, cfg.Observers)
+	if err != nil {
+		errorz.IgnoreClose(primary)
+		return nil, errorz.Wrap(err, errorz.SkipPackage())
+	}
+
+	return &instance{
+		cfg:              cfg,
+		tracker:          tracker,
+		primary:          primary,
+		primaryReadWrite: targetsReadWrite(cfg),
+		replicas:         replicas,
+	}, nil
+}
+
+// close drains the instance, then closes its backends.
+func (i *instance) close() {
+	drain(i.cfg, i.tracker)
+	errorz.IgnoreClose(i.replicas)
+	errorz.IgnoreClose(i.primary)
+}
+
+// handle holds the current instance for a named database, which can be swapped by Reload.
+type handle struct {
+	m        sync.Mutex
+	current  atomic.Value
+	released bool
+	wg       sync.WaitGroup
+}
+
+func newHandle(inst *instance) *handle {
+	h := &handle{}
+	h.current.Store(inst)
+	return h
+}
+
+func (h *handle) get() *instance {
+	return h.current.Load().(*instance)
+}
+
+func (h *handle) reload(ctx context.Context, cfg *Config) error {
+	if cfg.EnablePgxPoolMode != h.get().cfg.EnablePgxPoolMode {
+		return errorz.Errorf("pgx pool mode cannot be changed on reload", errorz.SkipPackage())
+	}
+
+	inst, err := openInstance(ctx, cfg)
+	if err != nil {
+		return errorz.Wrap(err, errorz.SkipPackage())
+	}
+
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	if h.released {
+		inst.close()
+		return errorz.Errorf("database is released", errorz.SkipPackage())
+	}
+
+	old := h.get()
+	h.current.Store(inst)
+
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		old.close()
+	}()
+
+	return nil
+}
+
+// beginTx tracks a new transaction on the current instance. If the instance is draining because it was swapped by a
+// reload in the meantime, it tries again on the new one, failing with ErrIDDraining only once the handle is released.
+func (h *handle) beginTx() (*instance, func(), error) {
+	for {
+		inst := h.get()
+
+		end, err := inst.tracker.beginTx()
+		if err == nil {
+			return inst, end, nil
+		}
+
+		// Reloads swap the instance before draining the old one, so a draining current instance means released.
+		if h.get() == inst {
+			return nil, nil, errorz.Wrap(err, errorz.SkipPackage())
+		}
+	}
+}
+
+func (h *handle) release() {
+	h.m.Lock()
+	h.released = true
+	h.m.Unlock()
+
+	h.wg.Wait()
+	h.get().close()
+}
+
+// Reload opens the primary and replicas from the given *Config and atomically swaps them with the ones found in
+// context, which are drained and closed in background. Transactions already running complete on the old ones.
+// The pgx pool mode cannot be changed on reload, and the *Config found in context is not affected. Values returned by
+// Get and its variants should not be retained across reloads, except for the ContextPG and ContextPgxPG ones, which
+// look up the current instance on each call.
+func Reload(ctx context.Context, cfg *Config) error {
+	return ReloadNamed(ctx, DefaultName, cfg)
+}
+
+// ReloadNamed is like Reload, but for the named database.
+func ReloadNamed(ctx context.Context, name string, cfg *Config) error {
+	return errorz.MaybeWrap(getHandle(ctx, name).reload(ctx, cfg), errorz.SkipPackage())
+}
+
+func getHandle(ctx context.Context, name string) *handle {
+	return ctx.Value(handleContextKey.named(name)).(*handle)
+}
+
+func getInstance(ctx context.Context, name string) *instance {
+	return getHandle(ctx, name).get()
+}
@@ -0,0 +1,142 @@
+package pgz
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ibrt/golang-errors/errorz"
+)
+
+const (
+	replicaHealthCheckInterval = 5 * time.Second
+	replicaHealthCheckTimeout  = 5 * time.Second
+)
+
+type replica struct {
+	*backend
+	healthy int32
+}
+
+func (r *replica) isHealthy() bool {
+	return atomic.LoadInt32(&r.healthy) == 1
+}
+
+func (r *replica) setHealthy(healthy bool) {
+	if healthy {
+		atomic.StoreInt32(&r.healthy, 1)
+	} else {
+		atomic.StoreInt32(&r.healthy, 0)
+	}
+}
+
+func (r *replica) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), replicaHealthCheckTimeout)
+	defer cancel()
+	r.setHealthy(r.ping(ctx) == nil)
+}
+
+// replicaSet is a set of read replicas, picked round-robin among the healthy ones.
+type replicaSet struct {
+	replicas []*replica
+	next     uint32
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+func openReplicaSet(cfg *Config, tracker *tracker, observers []Observer) (*replicaSet, error) {
+	s := &replicaSet{
+		replicas: make([]*replica, 0, len(cfg.ReplicaURLs)),
+		done:     make(chan struct{}),
+	}
+
+	replicaCfg := *cfg
+	replicaCfg.TargetSessionAttrs = ""
+
+	for _, replicaURL := range cfg.ReplicaURLs {
+		b, err := openBackend(&replicaCfg, replicaURL, tracker, observers)
+		if err != nil {
+			s.closeBackends()
+			return nil, errorz.Wrap(err, errorz.SkipPackage())
+		}
+		s.replicas = append(s.replicas, &replica{backend: b})
+	}
+
+	if !cfg.EnableLazyMode {
+		s.checkAll()
+	}
+
+	if len(s.replicas) > 0 {
+		s.wg.Add(1)
+		go s.run(cfg.EnableLazyMode)
+	}
+
+	return s, nil
+}
+
+func (s *replicaSet) run(checkNow bool) {
+	defer s.wg.Done()
+
+	if checkNow {
+		s.checkAll()
+	}
+
+	ticker := time.NewTicker(replicaHealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.checkAll()
+		}
+	}
+}
+
+func (s *replicaSet) checkAll() {
+	wg := &sync.WaitGroup{}
+
+	for _, r := range s.replicas {
+		wg.Add(1)
+		go func(r *replica) {
+			defer wg.Done()
+			r.check()
+		}(r)
+	}
+
+	wg.Wait()
+}
+
+// pick returns the next healthy replica, or nil if none is available.
+func (s *replicaSet) pick() *replica {
+	n := uint32(len(s.replicas))
+	if n == 0 {
+		return nil
+	}
+
+	start := atomic.AddUint32(&s.next, 1)
+
+	for i := uint32(0); i < n; i++ {
+		if r := s.replicas[(start+i)%n]; r.isHealthy() {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// Close implements the io.Closer interface.
+func (s *replicaSet) Close() error {
+	close(s.done)
+	s.wg.Wait()
+	s.closeBackends()
+	return nil
+}
+
+func (s *replicaSet) closeBackends() {
+	for _, r := range s.replicas {
+		errorz.IgnoreClose(r.backend)
+	}
+}
@@ -0,0 +1,73 @@
+package pgz
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	"github.com/ibrt/golang-errors/errorz"
+	"github.com/jackc/pgx/v4"
+)
+
+// SessionConfig describes the session runtime parameters set on every connection.
+// Zero values retain the server defaults, timeouts are expressed in milliseconds.
+type SessionConfig struct {
+	StatementTimeoutMillis                uint32 `json:"statementTimeoutMillis"`
+	LockTimeoutMillis                     uint32 `json:"lockTimeoutMillis"`
+	IdleInTransactionSessionTimeoutMillis uint32 `json:"idleInTransactionSessionTimeoutMillis"`
+	ApplicationName                       string `json:"applicationName"`
+	SearchPath                            string `json:"searchPath"`
+	TimeZone                              string `json:"timeZone"`
+}
+
+func (c *SessionConfig) runtimeParams() map[string]string {
+	params := make(map[string]string)
+
+	for k, v := range map[string]uint32{
+		"statement_timeout":                   c.StatementTimeoutMillis,
+		"lock_timeout":                        c.LockTimeoutMillis,
+		"idle_in_transaction_session_timeout": c.IdleInTransactionSessionTimeoutMillis,
+	} {
+		if v > 0 {
+			params[k] = strconv.FormatUint(uint64(v), 10)
+		}
+	}
+
+	for k, v := range map[string]string{
+		"application_name": c.ApplicationName,
+		"search_path":      c.SearchPath,
+		"timezone":         c.TimeZone,
+	} {
+		if v != "" {
+			params[k] = v
+		}
+	}
+
+	return params
+}
+
+// apply sets the session runtime parameters as startup parameters.
+func (c *SessionConfig) apply(connCfg *pgx.ConnConfig) {
+	for k, v := range c.runtimeParams() {
+		connCfg.RuntimeParams[k] = v
+	}
+}
+
+// afterConnect sets the session runtime parameters on an established connection, for use in proxy mode, where startup
+// parameters are not reliably forwarded to the server.
+func (c *SessionConfig) afterConnect(ctx context.Context, conn *pgx.Conn) error {
+	params := c.runtimeParams()
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if _, err := conn.Exec(ctx, `SELECT set_config($1, $2, false)`, k, params[k]); err != nil {
+			return errorz.Wrap(err, errorz.Prefix("failed to set %v", k), errorz.SkipPackage())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package pgz_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ibrt/golang-errors/errorz"
+	"github.com/ibrt/golang-fixtures/fixturez"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ibrt/golang-inject-pg/pgz"
+)
+
+func (s *Suite) TestSession(ctx context.Context, t *testing.T) {
+	for _, enableProxyMode := range []bool{false, true} {
+		sCtx, releaser := newTestContext(ctx, t, func(cfg *pgz.Config) {
+			cfg.EnableProxyMode = enableProxyMode
+			cfg.Session = &pgz.SessionConfig{
+				StatementTimeoutMillis:                1000,
+				LockTimeoutMillis:                     2000,
+				IdleInTransactionSessionTimeoutMillis: 3000,
+				ApplicationName:                       "pgz-test",
+				SearchPath:                            "pg_catalog, public",
+				TimeZone:                              "Europe/Rome",
+			}
+		})
+
+		for k, v := range map[string]string{
+			"statement_timeout":                   "1s",
+			"lock_timeout":                        "2s",
+			"idle_in_transaction_session_timeout": "3s",
+			"application_name":                    "pgz-test",
+			"search_path":                         "pg_catalog, public",
+			"timezone":                            "Europe/Rome",
+		} {
+			var setting string
+			row := pgz.GetCtx(sCtx).QueryRow(`SELECT current_setting($1)`, k)
+			fixturez.RequireNoError(t, row.Scan(&setting))
+			require.Equal(t, v, setting, k)
+		}
+
+		_, err := pgz.GetCtx(sCtx).Exec(`SELECT pg_sleep(2)`)
+		require.Error(t, err)
+		require.Equal(t, pgerrcode.QueryCanceled, errorz.Unwrap(err).(*pgconn.PgError).Code)
+
+		releaser()
+	}
+}
@@ -0,0 +1,199 @@
+package pgz
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ibrt/golang-errors/errorz"
+)
+
+const (
+	slowQueryExplainTimeout = 10 * time.Second
+	slowQueryMaxArgLength   = 64
+)
+
+var (
+	// slowQuerySkippedPrefixes lists the functions skipped when looking for the caller of a slow query.
+	slowQuerySkippedPrefixes = []string{
+		pkgPrefix,
+		"github.com/georgysavva/scany/",
+	}
+)
+
+// SlowQueryConfig describes how slow queries are logged, i.e. the ones running for at least ThresholdMillis. If
+// EnableExplain is set, the plans of slow SELECT queries are captured by running EXPLAIN in background on a separate
+// connection to the primary or replica that served them, and logged with them. At most one EXPLAIN runs at a time on
+// each primary or replica: slow queries ending while it runs are logged without a plan.
+type SlowQueryConfig struct {
+	ThresholdMillis uint32 `json:"thresholdMillis" validate:"required"`
+	EnableExplain   bool   `json:"explain"`
+}
+
+// slowQueryObserver is an Observer that logs the slow queries served by a backend.
+type slowQueryObserver struct {
+	cfg        *Config
+	threshold  time.Duration
+	tracker    *tracker
+	backend    *backend
+	explaining chan struct{}
+}
+
+func newSlowQueryObserver(cfg *Config, tracker *tracker, backend *backend) *slowQueryObserver {
+	return &slowQueryObserver{
+		cfg:        cfg,
+		threshold:  time.Duration(cfg.SlowQuery.ThresholdMillis) * time.Millisecond,
+		tracker:    tracker,
+		backend:    backend,
+		explaining: make(chan struct{}, 1),
+	}
+}
+
+// OnQueryStart implements the Observer interface.
+func (o *slowQueryObserver) OnQueryStart(context.Context, *QueryEvent) {}
+
+// OnQueryEnd implements the Observer interface.
+func (o *slowQueryObserver) OnQueryEnd(_ context.Context, e *QueryEvent) {
+	if e.Duration < o.threshold {
+		return
+	}
+
+	msg := fmt.Sprintf("pgz: slow query took %v at %v: %v; args: %v",
+		e.Duration.Round(time.Millisecond), getQueryCaller(), strings.Join(strings.Fields(e.SQL), " "), formatQueryArgs(e.Args))
+
+	if !o.cfg.SlowQuery.EnableExplain || !isSelectQuery(e.SQL) {
+		o.cfg.logf("%v", msg)
+		return
+	}
+
+	select {
+	case o.explaining <- struct{}{}:
+	default:
+		o.cfg.logf("%v; explain skipped", msg)
+		return
+	}
+
+	query, args := e.SQL, e.Args
+	end := o.tracker.trackQuery("EXPLAIN (FORMAT JSON) " + query)
+
+	go func() {
+		defer func() { <-o.explaining }()
+		defer end()
+
+		if plan, err := o.explain(query, args); err != nil {
+			o.cfg.logf("%v; explain failed: %v", msg, err)
+		} else {
+			o.cfg.logf("%v; plan: %v", msg, plan)
+		}
+	}()
+}
+
+// explain returns the plan of the given query as compact JSON.
+func (o *slowQueryObserver) explain(query string, args []interface{}) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), slowQueryExplainTimeout)
+	defer cancel()
+
+	var plan string
+	var err error
+
+	if o.backend.pool != nil {
+		err = o.backend.pool.QueryRow(ctx, "EXPLAIN (FORMAT JSON) "+query, args...).Scan(&plan)
+	} else {
+		err = o.backend.db.QueryRowContext(ctx, "EXPLAIN (FORMAT JSON) "+query, args...).Scan(&plan)
+	}
+	if err != nil {
+		return "", errorz.Wrap(err, errorz.SkipPackage())
+	}
+
+	b := &bytes.Buffer{}
+	if err := json.Compact(b, []byte(plan)); err != nil {
+		return "", errorz.Wrap(err, errorz.SkipPackage())
+	}
+	return b.String(), nil
+}
+
+// getQueryCaller returns the location of the first caller outside of this package and the scanning library.
+func getQueryCaller() string {
+	callers := make([]uintptr, 64)
+	callers = callers[:runtime.Callers(2, callers)]
+
+	for i, caller := range callers {
+		if f := runtime.FuncForPC(caller); f == nil || !hasAnyPrefix(f.Name(), slowQuerySkippedPrefixes) {
+			return errorz.FormatStackTrace(callers[i : i+1])[0]
+		}
+	}
+
+	return "unknown"
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isSelectQuery(query string) bool {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return false
+	}
+	keyword := strings.ToUpper(fields[0])
+	return keyword == "SELECT" || keyword == "WITH"
+}
+
+// formatQueryArgs formats query arguments for logging, truncating long values and omitting binary ones.
+func formatQueryArgs(args []interface{}) string {
+	formatted := make([]string, len(args))
+	for i, arg := range args {
+		formatted[i] = fmt.Sprintf("$%v=%v", i+1, formatQueryArg(arg))
+	}
+	return "[" + strings.Join(formatted, ", ") + "]"
+}
+
+func formatQueryArg(arg interface{}) string {
+	v := reflect.ValueOf(arg)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "NULL"
+		}
+		v = v.Elem()
+	}
+
+	if !v.IsValid() {
+		return "NULL"
+	}
+
+	switch arg := v.Interface().(type) {
+	case []byte:
+		return fmt.Sprintf("<%v bytes>", len(arg))
+	case string:
+		return strconv.Quote(truncateQueryArg(arg))
+	case time.Time:
+		return arg.Format(time.RFC3339Nano)
+	case driver.Valuer:
+		if value, err := arg.Value(); err == nil {
+			if _, ok := value.(driver.Valuer); !ok {
+				return formatQueryArg(value)
+			}
+		}
+	}
+
+	return truncateQueryArg(fmt.Sprint(v.Interface()))
+}
+
+func truncateQueryArg(s string) string {
+	if r := []rune(s); len(r) > slowQueryMaxArgLength {
+		return string(r[:slowQueryMaxArgLength]) + "..."
+	}
+	return s
+}
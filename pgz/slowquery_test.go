@@ -0,0 +1,115 @@
+package pgz_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ibrt/golang-fixtures/fixturez"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ibrt/golang-inject-pg/pgz"
+)
+
+func (s *Suite) TestSlowQuery(ctx context.Context, t *testing.T) {
+	_, err := pgz.GetCtx(ctx).Exec(`CREATE TABLE test_slow_query (id bigint NOT NULL PRIMARY KEY)`)
+	fixturez.RequireNoError(t, err)
+	fixturez.RequireNoError(t, pgz.ExecOne(ctx, `INSERT INTO test_slow_query (id) VALUES (1)`))
+
+	defer func() {
+		_, err := pgz.GetCtx(ctx).Exec(`DROP TABLE test_slow_query`)
+		fixturez.RequireNoError(t, err)
+	}()
+
+	for _, enablePgxPoolMode := range []bool{false, true} {
+		logger := &testLogger{}
+
+		sCtx, releaser := newTestContext(ctx, t, func(cfg *pgz.Config) {
+			cfg.EnablePgxPoolMode = enablePgxPoolMode
+			cfg.Logger = logger
+			cfg.DrainTimeoutSeconds = 10
+			cfg.SlowQuery = &pgz.SlowQueryConfig{
+				ThresholdMillis: 100,
+				EnableExplain:   true,
+			}
+		})
+
+		fixturez.RequireNoError(t, pgz.ExecOne(sCtx, `SELECT 1`))
+		fixturez.RequireNoError(t, pgz.ExecOne(sCtx, `SELECT pg_sleep($1::float8), $2::text`, 0.2, strings.Repeat("a", 100)))
+		fixturez.RequireNoError(t, pgz.ExecOne(sCtx, `WITH t AS (SELECT pg_sleep($1::float8)) SELECT * FROM t`, 0.2))
+
+		_, err = pgz.Select[int64](sCtx, `SELECT 1 FROM pg_sleep($1::float8)`, 0.2)
+		fixturez.RequireNoError(t, err)
+
+		err = pgz.NewTx(sCtx).Run(func(tCtx context.Context) error {
+			return pgz.ExecOne(tCtx, `SELECT pg_sleep(0.2)`)
+		})
+		fixturez.RequireNoError(t, err)
+
+		err = pgz.NewTx(sCtx).Run(func(tCtx context.Context) error {
+			// The lock blocks the EXPLAIN of the first query until commit, so the one of the second query is skipped.
+			var err error
+			if enablePgxPoolMode {
+				_, err = pgz.GetPgx(tCtx).Exec(tCtx, `LOCK TABLE test_slow_query IN ACCESS EXCLUSIVE MODE`)
+			} else {
+				_, err = pgz.GetCtx(tCtx).Exec(`LOCK TABLE test_slow_query IN ACCESS EXCLUSIVE MODE`)
+			}
+			if err != nil {
+				return err
+			}
+			if err := pgz.ExecOne(tCtx, `SELECT pg_sleep(0.2), 1 FROM test_slow_query`); err != nil {
+				return err
+			}
+			return pgz.ExecOne(tCtx, `SELECT pg_sleep(0.2), 2 FROM test_slow_query`)
+		})
+		fixturez.RequireNoError(t, err)
+
+		if enablePgxPoolMode {
+			_, err = pgz.GetPgx(sCtx).Exec(sCtx, `DO $$ BEGIN PERFORM pg_sleep(0.2); END $$`)
+		} else {
+			_, err = pgz.GetCtx(sCtx).Exec(`DO $$ BEGIN PERFORM pg_sleep(0.2); END $$`)
+		}
+		fixturez.RequireNoError(t, err)
+
+		releaser()
+
+		lines := logger.getLines()
+		require.Len(t, lines, 7)
+
+		for _, line := range lines {
+			require.True(t, strings.HasPrefix(line, "pgz: slow query took "), line)
+			require.Contains(t, line, "slowquery_test.go:")
+			require.Contains(t, line, "TestSlowQuery")
+		}
+
+		requireLogLine(t, lines, `SELECT pg_sleep($1::float8), $2::text; args: [$1=0.2, $2="`+strings.Repeat("a", 64)+`..."]; plan: [{"Plan":`)
+		requireLogLine(t, lines, `WITH t AS (SELECT pg_sleep($1::float8)) SELECT * FROM t; args: [$1=0.2]; plan: [{"Plan":`)
+		requireLogLine(t, lines, `SELECT 1 FROM pg_sleep($1::float8); args: [$1=0.2]; plan: [{"Plan":`)
+		requireLogLine(t, lines, `SELECT pg_sleep(0.2); args: []; plan: [{"Plan":`)
+		requireLogLine(t, lines, `SELECT pg_sleep(0.2), 1 FROM test_slow_query; args: []; plan: [{"Plan":`)
+		requireLogLine(t, lines, `SELECT pg_sleep(0.2), 2 FROM test_slow_query; args: []; explain skipped`)
+		requireLogLine(t, lines, `DO $$ BEGIN PERFORM pg_sleep(0.2); END $$; args: []`)
+	}
+}
+
+func TestConfig_ValidateSlowQuery(t *testing.T) {
+	cfg := &pgz.Config{
+		PostgresURL: "postgres://postgres:password@pg:5432/postgres",
+		SlowQuery: &pgz.SlowQueryConfig{
+			ThresholdMillis: 100,
+		},
+	}
+	fixturez.RequireNoError(t, cfg.Validate())
+
+	cfg.SlowQuery.ThresholdMillis = 0
+	require.Error(t, cfg.Validate())
+}
+
+func requireLogLine(t *testing.T, lines []string, contains string) {
+	for _, line := range lines {
+		if strings.Contains(line, contains) {
+			return
+		}
+	}
+	require.FailNow(t, "log line not found", "%v\n%v", contains, strings.Join(lines, "\n"))
+}
@@ -0,0 +1,81 @@
+package pgz
+
+import (
+	"context"
+	"time"
+
+	"github.com/ibrt/golang-errors/errorz"
+)
+
+const (
+	defaultStartupInitialBackoff = 100 * time.Millisecond
+	defaultStartupMaxBackoff     = 10 * time.Second
+)
+
+// StartupRetryConfig describes how Initializer retries connecting to the database on startup, with exponential
+// backoff. At least one of MaxAttempts and DeadlineSeconds must be set, zero means unlimited.
+type StartupRetryConfig struct {
+	MaxAttempts          uint32 `json:"maxAttempts" validate:"required_without=DeadlineSeconds"`
+	InitialBackoffMillis uint32 `json:"initialBackoffMillis"`
+	MaxBackoffMillis     uint32 `json:"maxBackoffMillis"`
+	DeadlineSeconds      uint32 `json:"deadlineSeconds"`
+}
+
+// pingWithRetry pings the backend, retrying according to cfg.StartupRetry, returns the last error on failure.
+func pingWithRetry(ctx context.Context, cfg *Config, b *backend) error {
+	retry := cfg.StartupRetry
+	if retry == nil {
+		retry = &StartupRetryConfig{MaxAttempts: 1}
+	}
+
+	if deadline := time.Duration(retry.DeadlineSeconds) * time.Second; deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, deadline)
+		defer cancel()
+	}
+
+	backoff := durationOrDefault(retry.InitialBackoffMillis, defaultStartupInitialBackoff)
+	maxBackoff := durationOrDefault(retry.MaxBackoffMillis, defaultStartupMaxBackoff)
+
+	for attempt := uint32(1); ; attempt++ {
+		err := pingOnce(ctx, cfg, b)
+		if err == nil {
+			return nil
+		}
+
+		if (retry.MaxAttempts > 0 && attempt >= retry.MaxAttempts) || ctx.Err() != nil {
+			cfg.logf("pgz: ping attempt %v failed, giving up: %v", attempt, err)
+			return errorz.Wrap(err, errorz.SkipPackage())
+		}
+
+		cfg.logf("pgz: ping attempt %v failed, retrying in %v: %v", attempt, backoff, err)
+
+		select {
+		case <-ctx.Done():
+			cfg.logf("pgz: startup deadline exceeded, giving up")
+			return errorz.Wrap(err, errorz.SkipPackage())
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+func pingOnce(ctx context.Context, cfg *Config, b *backend) error {
+	if timeout := time.Duration(cfg.ConnectTimeoutSeconds) * time.Second; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	return errorz.MaybeWrap(b.ping(ctx), errorz.SkipPackage())
+}
+
+func durationOrDefault(millis uint32, def time.Duration) time.Duration {
+	if millis > 0 {
+		return time.Duration(millis) * time.Millisecond
+	}
+	return def
+}
@@ -0,0 +1,98 @@
+package pgz
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/ibrt/golang-errors/errorz"
+)
+
+const (
+	// DefaultStreamChunkSize is the number of rows fetched at a time by Stream.
+	DefaultStreamChunkSize = 1000
+)
+
+var (
+	nextCursorID uint64
+)
+
+// Stream runs a query through a server-side cursor, calling f for each row, scanned as in Select. Rows are fetched in
+// chunks of DefaultStreamChunkSize, so that only one chunk is buffered on the client at a time. The cursor is declared
+// within the current transaction if any, or within a new one otherwise, which is not retried on failure (e.g. on
+// serialization failures) as f may have already processed some rows. Returning an error from f stops the stream.
+func Stream[T any](ctx context.Context, query string, args []interface{}, f func(row T) error) error {
+	return StreamNamed(ctx, DefaultName, query, args, f)
+}
+
+// StreamNamed is like Stream, but for the named database.
+func StreamNamed[T any](ctx context.Context, name, query string, args []interface{}, f func(row T) error) error {
+	return StreamChunksNamed(ctx, name, DefaultStreamChunkSize, query, args, func(rows []T) error {
+		for _, row := range rows {
+			if err := f(row); err != nil {
+				return errorz.Wrap(err, errorz.SkipPackage())
+			}
+		}
+		return nil
+	})
+}
+
+// StreamChunks is like Stream, but calls f for each chunk of at most chunkSize rows. The next chunk is not fetched
+// until f returns.
+func StreamChunks[T any](ctx context.Context, chunkSize int, query string, args []interface{}, f func(rows []T) error) error {
+	return StreamChunksNamed(ctx, DefaultName, chunkSize, query, args, f)
+}
+
+// StreamChunksNamed is like StreamChunks, but for the named database.
+func StreamChunksNamed[T any](ctx context.Context, name string, chunkSize int, query string, args []interface{}, f func(rows []T) error) error {
+	if chunkSize <= 0 {
+		return errorz.Errorf("invalid chunk size: %v", errorz.A(chunkSize), errorz.SkipPackage())
+	}
+
+	// The transaction is never retried, as f would get the chunks it already processed again.
+	tx := NewTx(ctx).SetDatabaseName(name)
+	tx.noRetry = true
+
+	return errorz.MaybeWrap(tx.Run(func(ctx context.Context) error {
+		cursor := fmt.Sprintf("pgz_cursor_%v", atomic.AddUint64(&nextCursorID, 1))
+
+		if err := execStream(ctx, name, fmt.Sprintf("DECLARE %v NO SCROLL CURSOR FOR %v", cursor, query), args...); err != nil {
+			return errorz.Wrap(err, errorz.SkipPackage())
+		}
+
+		fetch := fmt.Sprintf("FETCH FORWARD %v FROM %v", chunkSize, cursor)
+
+		for {
+			if err := ctx.Err(); err != nil {
+				return errorz.Wrap(err, errorz.SkipPackage())
+			}
+
+			rows, err := SelectNamed[T](ctx, name, fetch)
+			if err != nil {
+				return errorz.Wrap(err, errorz.SkipPackage())
+			}
+
+			if len(rows) > 0 {
+				if err := f(rows); err != nil {
+					return errorz.Wrap(err, errorz.SkipPackage())
+				}
+			}
+
+			if len(rows) < chunkSize {
+				break
+			}
+		}
+
+		return errorz.MaybeWrap(execStream(ctx, name, "CLOSE "+cursor), errorz.SkipPackage())
+	}), errorz.SkipPackage())
+}
+
+func execStream(ctx context.Context, name, query string, args ...interface{}) error {
+	if isPgxPoolMode(ctx, name) {
+		_, err := GetNamedPgx(ctx, name).Exec(ctx, query, args...)
+		return errorz.MaybeWrap(err, errorz.SkipPackage())
+	}
+
+	_, err := GetNamed(ctx, name).ExecContext(ctx, query, args...)
+	return errorz.MaybeWrap(err, errorz.SkipPackage())
+}
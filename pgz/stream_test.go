@@ -0,0 +1,133 @@
+package pgz_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ibrt/golang-errors/errorz"
+	"github.com/ibrt/golang-fixtures/fixturez"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ibrt/golang-inject-pg/pgz"
+)
+
+func (s *Suite) TestStream(ctx context.Context, t *testing.T) {
+	const query = `SELECT i AS id, 'n' || i AS name FROM generate_series(1, $1::int) AS i ORDER BY i`
+
+	// The suite runs in proxy mode: the cursor is also declared with bind arguments using the extended protocol.
+	extendedCtx, _ := newTestContext(ctx, t, func(cfg *pgz.Config) {
+		cfg.EnableProxyMode = false
+	})
+	extendedPgxCtx, _ := newTestContext(ctx, t, func(cfg *pgz.Config) {
+		cfg.EnableProxyMode = false
+		cfg.EnablePgxPoolMode = true
+	})
+
+	for _, sCtx := range []context.Context{ctx, newPgxContext(ctx, t), extendedCtx, extendedPgxCtx} {
+		rows := make([]*testQueryRow, 0)
+		err := pgz.Stream(sCtx, query, []interface{}{2500}, func(row *testQueryRow) error {
+			rows = append(rows, row)
+			return nil
+		})
+		fixturez.RequireNoError(t, err)
+		require.Len(t, rows, 2500)
+		require.Equal(t, &testQueryRow{ID: 2500, Name: "n2500"}, rows[2499])
+
+		chunks := make([]int, 0)
+		err = pgz.StreamChunks(sCtx, 2, query, []interface{}{5}, func(rows []testQueryRow) error {
+			chunks = append(chunks, len(rows))
+			return nil
+		})
+		fixturez.RequireNoError(t, err)
+		require.Equal(t, []int{2, 2, 1}, chunks)
+
+		chunks = make([]int, 0)
+		err = pgz.StreamChunks(sCtx, 2, query, []interface{}{4}, func(rows []testQueryRow) error {
+			chunks = append(chunks, len(rows))
+			return nil
+		})
+		fixturez.RequireNoError(t, err)
+		require.Equal(t, []int{2, 2}, chunks)
+
+		err = pgz.StreamChunks(sCtx, 2, query, []interface{}{0}, func(rows []testQueryRow) error {
+			require.FailNow(t, "unexpected call")
+			return nil
+		})
+		fixturez.RequireNoError(t, err)
+
+		ids := make([]int64, 0)
+		err = pgz.NewTx(sCtx).Run(func(tCtx context.Context) error {
+			return pgz.Stream(tCtx, `SELECT generate_series(1, 3)`, nil, func(id int64) error {
+				ids = append(ids, id)
+				return nil
+			})
+		})
+		fixturez.RequireNoError(t, err)
+		require.Equal(t, []int64{1, 2, 3}, ids)
+
+		cCtx, cancel := context.WithCancel(sCtx)
+		err = pgz.StreamChunks(cCtx, 1, query, []interface{}{3}, func(rows []testQueryRow) error {
+			cancel()
+			return nil
+		})
+		require.Equal(t, context.Canceled, errorz.Unwrap(err))
+
+		calls := 0
+		err = pgz.StreamChunks(sCtx, 3, query, []interface{}{5}, func(rows []testQueryRow) error {
+			calls++
+			// Terminating the backend of the stream would cause a retryable transaction to run again.
+			_, err := pgz.SelectScalar[bool](ctx,
+				`SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE query LIKE 'FETCH FORWARD 3 FROM pgz\_cursor\_%'`)
+			return err
+		})
+		require.Error(t, err)
+		require.Equal(t, 1, calls)
+
+		err = pgz.Stream(sCtx, query, []interface{}{3}, func(row testQueryRow) error {
+			return errorz.Errorf("test error")
+		})
+		require.EqualError(t, err, "test error")
+
+		err = pgz.StreamChunks(sCtx, 0, query, nil, func(rows []testQueryRow) error {
+			return nil
+		})
+		require.EqualError(t, err, "invalid chunk size: 0")
+	}
+}
+
+func (s *Suite) TestStream_Named(ctx context.Context, t *testing.T) {
+	const readOnlyQuery = `SELECT current_setting('transaction_read_only') = 'on'`
+
+	for _, sCtx := range []context.Context{ctx, newPgxContext(ctx, t)} {
+		nCtx := newNamedTestContext(sCtx, t, "analytics")
+
+		// Within the read-only transaction on the named database, the cursor is declared in it.
+		err := pgz.NewTx(nCtx).SetDatabaseName("analytics").SetReadOnly(true).Run(func(tCtx context.Context) error {
+			readOnly := make([]bool, 0)
+
+			err := pgz.StreamNamed(tCtx, "analytics", readOnlyQuery, nil, func(row bool) error {
+				readOnly = append(readOnly, row)
+				return nil
+			})
+			fixturez.RequireNoError(t, err)
+
+			err = pgz.StreamChunksNamed(tCtx, "analytics", 1, readOnlyQuery, nil, func(rows []bool) error {
+				readOnly = append(readOnly, rows...)
+				return nil
+			})
+			fixturez.RequireNoError(t, err)
+
+			require.Equal(t, []bool{true, true}, readOnly)
+			return nil
+		})
+		fixturez.RequireNoError(t, err)
+
+		ids := make([]int64, 0)
+		err = pgz.StreamNamed(nCtx, "analytics", `SELECT generate_series(1, $1::int)`, []interface{}{3}, func(id int64) error {
+			ids = append(ids, id)
+			return nil
+		})
+		fixturez.RequireNoError(t, err)
+		require.Equal(t, []int64{1, 2, 3}, ids)
+	}
+}
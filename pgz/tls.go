@@ -0,0 +1,198 @@
+package pgz
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/ibrt/golang-errors/errorz"
+	"github.com/ibrt/golang-validation/vz"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// TLS modes.
+const (
+	TLSModeDisable    = "disable"
+	TLSModeRequire    = "require"
+	TLSModeVerifyCA   = "verify-ca"
+	TLSModeVerifyFull = "verify-full"
+)
+
+// TLSConfig describes the TLS configuration for PG, it overrides the "ssl*" parameters in PostgresURL.
+// Certificates and keys can be given either as PEM or as path to a PEM file. As in libpq, a "require" mode with a
+// root CA behaves like "verify-ca". If ServerName is empty, "verify-full" checks each server against its host name.
+type TLSConfig struct {
+	Mode           string `json:"mode" validate:"required,oneof=disable require verify-ca verify-full"`
+	RootCAPEM      string `json:"rootCaPem" validate:"excluded_with=RootCAPath"`
+	RootCAPath     string `json:"rootCaPath"`
+	ClientCertPEM  string `json:"clientCertPem" validate:"excluded_with=ClientCertPath"`
+	ClientCertPath string `json:"clientCertPath"`
+	ClientKeyPEM   string `json:"clientKeyPem" validate:"excluded_with=ClientKeyPath"`
+	ClientKeyPath  string `json:"clientKeyPath"`
+	ServerName     string `json:"serverName"`
+}
+
+// Validate implements the vz.Validator interface.
+func (c *TLSConfig) Validate() error {
+	if err := vz.ValidateStruct(c); err != nil {
+		return errorz.Wrap(err, errorz.SkipPackage())
+	}
+
+	hasRootCA := c.RootCAPEM != "" || c.RootCAPath != ""
+	hasClientCert := c.ClientCertPEM != "" || c.ClientCertPath != ""
+	hasClientKey := c.ClientKeyPEM != "" || c.ClientKeyPath != ""
+
+	if c.Mode == TLSModeDisable && (hasRootCA || hasClientCert || hasClientKey || c.ServerName != "") {
+		return vz.NewErrFailedValidation("tls settings given with disabled tls", map[string]interface{}{
+			"mode": "disable",
+		}, errorz.SkipPackage())
+	}
+
+	if hasClientCert != hasClientKey {
+		return vz.NewErrFailedValidation("client cert and key must be given together", map[string]interface{}{
+			"clientCert": "required_with",
+			"clientKey":  "required_with",
+		}, errorz.SkipPackage())
+	}
+
+	if c.ServerName != "" && c.Mode != TLSModeVerifyFull {
+		return vz.NewErrFailedValidation("server name given without verify-full", map[string]interface{}{
+			"serverName": "excluded_unless",
+		}, errorz.SkipPackage())
+	}
+
+	return nil
+}
+
+func (c *TLSConfig) build() (*tls.Config, error) {
+	if c.Mode == TLSModeDisable {
+		return nil, nil
+	}
+
+	tlsCfg := &tls.Config{
+		ServerName: c.ServerName,
+	}
+
+	rootCAPEM, err := readPEM(c.RootCAPEM, c.RootCAPath)
+	if err != nil {
+		return nil, errorz.Wrap(err, errorz.Prefix("unable to read root CA"), errorz.SkipPackage())
+	}
+
+	if rootCAPEM != nil {
+		tlsCfg.RootCAs = x509.NewCertPool()
+		if !tlsCfg.RootCAs.AppendCertsFromPEM(rootCAPEM) {
+			return nil, errorz.Errorf("unable to parse root CA", errorz.SkipPackage())
+		}
+	}
+
+	if c.Mode == TLSModeVerifyCA || (c.Mode == TLSModeRequire && rootCAPEM != nil) {
+		tlsCfg.InsecureSkipVerify = true
+		tlsCfg.VerifyPeerCertificate = newVerifyCA(tlsCfg.RootCAs)
+	} else if c.Mode == TLSModeRequire {
+		tlsCfg.InsecureSkipVerify = true
+	}
+
+	clientCertPEM, err := readPEM(c.ClientCertPEM, c.ClientCertPath)
+	if err != nil {
+		return nil, errorz.Wrap(err, errorz.Prefix("unable to read client cert"), errorz.SkipPackage())
+	}
+
+	clientKeyPEM, err := readPEM(c.ClientKeyPEM, c.ClientKeyPath)
+	if err != nil {
+		return nil, errorz.Wrap(err, errorz.Prefix("unable to read client key"), errorz.SkipPackage())
+	}
+
+	if clientCertPEM != nil {
+		cert, err := tls.X509KeyPair(clientCertPEM, clientKeyPEM)
+		if err != nil {
+			return nil, errorz.Wrap(err, errorz.Prefix("unable to parse client cert"), errorz.SkipPackage())
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsCfg, nil
+}
+
+// apply sets the TLS configuration on the given pgx.ConnConfig and its fallbacks, replacing the ones derived from the
+// "ssl*" parameters, and dropping the fallbacks that only differed by TLS configuration.
+func (c *TLSConfig) apply(connCfg *pgx.ConnConfig) error {
+	tlsCfg, err := c.build()
+	if err != nil {
+		return errorz.Wrap(err, errorz.SkipPackage())
+	}
+
+	forHost := func(host string) *tls.Config {
+		if tlsCfg == nil || c.Mode != TLSModeVerifyFull || c.ServerName != "" {
+			return tlsCfg
+		}
+		hostTLSCfg := tlsCfg.Clone()
+		hostTLSCfg.ServerName = host
+		return hostTLSCfg
+	}
+
+	connCfg.TLSConfig = forHost(connCfg.Host)
+	fallbacks := make([]*pgconn.FallbackConfig, 0, len(connCfg.Fallbacks))
+	seen := map[string]struct{}{
+		net.JoinHostPort(connCfg.Host, strconv.Itoa(int(connCfg.Port))): {},
+	}
+
+	for _, fallback := range connCfg.Fallbacks {
+		hostPort := net.JoinHostPort(fallback.Host, strconv.Itoa(int(fallback.Port)))
+		if _, ok := seen[hostPort]; ok {
+			continue
+		}
+		seen[hostPort] = struct{}{}
+
+		fallbacks = append(fallbacks, &pgconn.FallbackConfig{
+			Host:      fallback.Host,
+			Port:      fallback.Port,
+			TLSConfig: forHost(fallback.Host),
+		})
+	}
+
+	connCfg.Fallbacks = fallbacks
+	return nil
+}
+
+// newVerifyCA verifies the server certificate chain without checking the host name, emulating libpq's verify-ca.
+func newVerifyCA(rootCAs *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errorz.Errorf("no server certificate", errorz.SkipPackage())
+		}
+
+		certs := make([]*x509.Certificate, len(rawCerts))
+		for i, rawCert := range rawCerts {
+			cert, err := x509.ParseCertificate(rawCert)
+			if err != nil {
+				return errorz.Wrap(err, errorz.Prefix("failed to parse server certificate"), errorz.SkipPackage())
+			}
+			certs[i] = cert
+		}
+
+		opts := x509.VerifyOptions{
+			Roots:         rootCAs,
+			Intermediates: x509.NewCertPool(),
+		}
+		for _, cert := range certs[1:] {
+			opts.Intermediates.AddCert(cert)
+		}
+
+		_, err := certs[0].Verify(opts)
+		return errorz.MaybeWrap(err, errorz.SkipPackage())
+	}
+}
+
+func readPEM(pem, path string) ([]byte, error) {
+	if pem != "" {
+		return []byte(pem), nil
+	}
+	if path != "" {
+		buf, err := os.ReadFile(path)
+		return buf, errorz.MaybeWrap(err, errorz.SkipPackage())
+	}
+	return nil, nil
+}
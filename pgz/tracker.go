@@ -0,0 +1,164 @@
+package pgz
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ibrt/golang-errors/errorz"
+)
+
+const (
+	// ErrIDDraining is an error ID.
+	ErrIDDraining = errorz.ID("draining")
+)
+
+const (
+	pkgPrefix         = "github.com/ibrt/golang-inject-pg/pgz."
+	opKindTransaction = "transaction"
+	opKindQuery       = "query"
+)
+
+// activeOp describes a transaction or query in progress.
+type activeOp struct {
+	kind    string
+	query   string
+	start   time.Time
+	callers []uintptr
+}
+
+func (o *activeOp) String() string {
+	b := &strings.Builder{}
+	_, _ = fmt.Fprintf(b, "%v running for %v", o.kind, time.Since(o.start).Round(time.Millisecond))
+	if o.query != "" {
+		_, _ = fmt.Fprintf(b, ": %v", strings.Join(strings.Fields(o.query), " "))
+	}
+	for _, frame := range errorz.FormatStackTrace(skipPackageCallers(o.callers)) {
+		_, _ = fmt.Fprintf(b, "\n\t%v", frame)
+	}
+	return b.String()
+}
+
+// tracker keeps track of the active transactions and queries, so that they can be drained before closing the pool.
+// It does not lock, as it is used on every query.
+type tracker struct {
+	nextID    uint64
+	count     int64
+	draining  int32
+	active    sync.Map
+	drained   chan struct{}
+	closeOnce sync.Once
+}
+
+func newTracker() *tracker {
+	return &tracker{
+		drained: make(chan struct{}),
+	}
+}
+
+// beginTx tracks a new transaction, fails if draining. Unlike queries, transactions record their callers.
+func (t *tracker) beginTx() (func(), error) {
+	end, ok := t.begin(&activeOp{kind: opKindTransaction, start: time.Now(), callers: getCallers()}, false)
+	if !ok {
+		return nil, errorz.Errorf("database is draining", ErrIDDraining, errorz.SkipPackage())
+	}
+	return end, nil
+}
+
+// trackQuery tracks a new query, which is allowed while draining. The returned function can be called more than once.
+func (t *tracker) trackQuery(query string) func() {
+	end, _ := t.begin(&activeOp{kind: opKindQuery, query: query, start: time.Now()}, true)
+	return end
+}
+
+func (t *tracker) begin(op *activeOp, allowDraining bool) (func(), bool) {
+	// Incrementing count before checking draining guarantees that drain either sees the operation or rejects it.
+	atomic.AddInt64(&t.count, 1)
+
+	if !allowDraining && atomic.LoadInt32(&t.draining) == 1 {
+		t.done()
+		return nil, false
+	}
+
+	id := atomic.AddUint64(&t.nextID, 1)
+	t.active.Store(id, op)
+
+	return func() {
+		if _, ok := t.active.LoadAndDelete(id); ok {
+			t.done()
+		}
+	}, true
+}
+
+func (t *tracker) done() {
+	if atomic.AddInt64(&t.count, -1) == 0 && atomic.LoadInt32(&t.draining) == 1 {
+		t.closeOnce.Do(func() { close(t.drained) })
+	}
+}
+
+// drain stops accepting new transactions, then waits for the active ones and the queries to complete up to the given
+// timeout. It returns the operations still running after the timeout, oldest first.
+func (t *tracker) drain(timeout time.Duration) []*activeOp {
+	atomic.StoreInt32(&t.draining, 1)
+	if atomic.LoadInt64(&t.count) == 0 {
+		t.closeOnce.Do(func() { close(t.drained) })
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-t.drained:
+		return nil
+	case <-timer.C:
+		select {
+		case <-t.drained:
+			return nil
+		default:
+		}
+	}
+
+	ops := make([]*activeOp, 0)
+	t.active.Range(func(_, op interface{}) bool {
+		ops = append(ops, op.(*activeOp))
+		return true
+	})
+	sort.Slice(ops, func(i, j int) bool {
+		return ops[i].start.Before(ops[j].start)
+	})
+
+	return ops
+}
+
+// drain drains the tracker up to Config.DrainTimeoutSeconds, logging the operations still running after it.
+func drain(cfg *Config, t *tracker) {
+	ops := t.drain(time.Duration(cfg.DrainTimeoutSeconds) * time.Second)
+	if len(ops) == 0 {
+		return
+	}
+
+	cfg.logf("pgz: drain timeout exceeded, closing with %v operation(s) still running", len(ops))
+	for _, op := range ops {
+		cfg.logf("pgz: %v", op)
+	}
+}
+
+// getCallers returns the current stack trace, it is only resolved (see skipPackageCallers) if logged.
+func getCallers() []uintptr {
+	callers := make([]uintptr, 32)
+	return callers[:runtime.Callers(3, callers)]
+}
+
+// skipPackageCallers skips the leading frames from this package.
+func skipPackageCallers(callers []uintptr) []uintptr {
+	for i, caller := range callers {
+		if f := runtime.FuncForPC(caller); f == nil || !strings.HasPrefix(f.Name(), pkgPrefix) {
+			return callers[i:]
+		}
+	}
+	return callers
+}
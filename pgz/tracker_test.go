@@ -0,0 +1,135 @@
+package pgz_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ibrt/golang-errors/errorz"
+	"github.com/ibrt/golang-fixtures/fixturez"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ibrt/golang-inject-pg/pgz"
+)
+
+func (s *Suite) TestDrain(ctx context.Context, t *testing.T) {
+	logger := &testLogger{}
+
+	dCtx, releaser := newTestContext(ctx, t, func(cfg *pgz.Config) {
+		cfg.DrainTimeoutSeconds = 5
+		cfg.Logger = logger
+	})
+
+	started := make(chan struct{})
+	done := make(chan error)
+
+	go func() {
+		done <- pgz.NewTx(dCtx).Run(func(ctx context.Context) error {
+			close(started)
+			time.Sleep(500 * time.Millisecond)
+			_, err := pgz.GetCtx(ctx).Exec(`SELECT 1`)
+			return err
+		})
+	}()
+
+	<-started
+	start := time.Now()
+	draining := make(chan error, 1)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		draining <- pgz.NewTx(dCtx).Run(func(ctx context.Context) error {
+			return nil
+		})
+	}()
+	releaser()
+
+	fixturez.RequireNoError(t, <-done)
+	require.GreaterOrEqual(t, int64(time.Since(start)), int64(300*time.Millisecond))
+	require.Empty(t, logger.getLines())
+
+	err := <-draining
+	require.Error(t, err)
+	require.Equal(t, pgz.ErrIDDraining, errorz.GetID(err))
+}
+
+func (s *Suite) TestDrain_Rows(ctx context.Context, t *testing.T) {
+	for _, enablePgxPoolMode := range []bool{false, true} {
+		dCtx, releaser := newTestContext(ctx, t, func(cfg *pgz.Config) {
+			cfg.EnablePgxPoolMode = enablePgxPoolMode
+			cfg.DrainTimeoutSeconds = 5
+		})
+
+		var next func() bool
+		var closeRows func()
+
+		if enablePgxPoolMode {
+			rows, err := pgz.GetPgxCtx(dCtx).Query(`SELECT generate_series(1, 3)`)
+			fixturez.RequireNoError(t, err)
+			next, closeRows = rows.Next, rows.Close
+		} else {
+			rows, err := pgz.GetCtx(dCtx).Query(`SELECT generate_series(1, 3)`)
+			fixturez.RequireNoError(t, err)
+			next, closeRows = rows.Next, func() { errorz.IgnoreClose(rows) }
+		}
+
+		released := make(chan struct{})
+		go func() {
+			defer close(released)
+			releaser()
+		}()
+
+		require.True(t, next())
+
+		select {
+		case <-released:
+			require.FailNow(t, "released with rows still open")
+		case <-time.After(300 * time.Millisecond):
+		}
+
+		closeRows()
+
+		select {
+		case <-released:
+		case <-time.After(time.Second):
+			require.FailNow(t, "not released after closing rows")
+		}
+	}
+}
+
+func (s *Suite) TestDrain_Timeout(ctx context.Context, t *testing.T) {
+	logger := &testLogger{}
+
+	dCtx, releaser := newTestContext(ctx, t, func(cfg *pgz.Config) {
+		cfg.DrainTimeoutSeconds = 1
+		cfg.Logger = logger
+	})
+
+	started := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		_ = pgz.NewTx(dCtx).Run(func(ctx context.Context) error {
+			close(started)
+			_, err := pgz.GetCtx(ctx).Exec(`SELECT pg_sleep(3)`)
+			return err
+		})
+	}()
+
+	<-started
+	time.Sleep(100 * time.Millisecond)
+	start := time.Now()
+	releaser()
+	require.Less(t, int64(time.Since(start)), int64(2*time.Second))
+	<-done
+
+	lines := logger.getLines()
+	require.Len(t, lines, 3)
+	require.Equal(t, "pgz: drain timeout exceeded, closing with 2 operation(s) still running", lines[0])
+	require.True(t, strings.HasPrefix(lines[1], "pgz: transaction running for "), lines[1])
+	require.Contains(t, lines[1], "tracker_test.go")
+	require.True(t, strings.HasPrefix(lines[2], "pgz: query running for "), lines[2])
+	require.Contains(t, lines[2], "SELECT pg_sleep(3)")
+	require.NotContains(t, lines[2], "tracker_test.go")
+}
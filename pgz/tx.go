@@ -12,27 +12,37 @@ import (
 )
 
 const (
-	txMaxRetries = 10
+	txMaxRetries      = 10
+	txFailoverBackoff = 200 * time.Millisecond
 )
 
 // Tx describes a transaction.
 type Tx struct {
 	ctx            context.Context
+	name           string
 	isolationLevel sql.IsolationLevel
 	readOnly       bool
 	allowReentrant bool
+	noRetry        bool
 }
 
 // NewTx initializes a new Tx.
 func NewTx(ctx context.Context) *Tx {
 	return &Tx{
 		ctx:            ctx,
+		name:           DefaultName,
 		isolationLevel: sql.LevelDefault,
 		readOnly:       false,
 		allowReentrant: true,
 	}
 }
 
+// SetDatabaseName sets the name of the database targeted by the transaction.
+func (t *Tx) SetDatabaseName(name string) *Tx {
+	t.name = name
+	return t
+}
+
 // SetIsolationLevel sets the isolation level.
 func (t *Tx) SetIsolationLevel(isolationLevel sql.IsolationLevel) *Tx {
 	t.isolationLevel = isolationLevel
@@ -51,25 +61,63 @@ func (t *Tx) SetAllowReentrant(allowReentrant bool) *Tx {
 	return t
 }
 
-// Run runs the transaction.
+// Run runs the transaction. It is retried, calling f again, on serialization failures, and if the connection is
+// terminated by the server (e.g. on failover) before committing. If TargetSessionAttrs (or the "target_session_attrs"
+// parameter of PostgresURL) is "read-write" or "primary", read-write transactions are also retried if they fail because
+// the server is read-only, e.g. if the primary was demoted. As such, f may run more than once.
 func (t *Tx) Run(f func(ctx context.Context) error) error {
-	if _, ok := t.ctx.Value(dbContextKey).(*sql.Tx); ok {
+	if isInTx(t.ctx, t.name) {
 		if !t.allowReentrant {
 			return errorz.Errorf("unexpectedly nested transaction", errorz.SkipPackage())
 		}
 		return errorz.MaybeWrap(f(t.ctx), errorz.SkipPackage())
 	}
 
+	inst, end, err := getHandle(t.ctx, t.name).beginTx()
+	if err != nil {
+		return errorz.Wrap(err, errorz.SkipPackage())
+	}
+	defer end()
+
 	for i := 0; i < txMaxRetries; i++ {
-		err := t.runTxOnce(f)
+		r, committing, err := t.runTxOnce(inst, f)
 		if err == nil {
 			return nil
 		}
 
-		pgErr, ok := errorz.Unwrap(err).(*pgconn.PgError)
-		if ok && pgErr.Code == pgerrcode.SerializationFailure && i < txMaxRetries-1 {
-			time.Sleep(time.Duration(100 + (500*rand.Float64())*float64(time.Millisecond)))
-			continue
+		if pgErr, ok := errorz.Unwrap(err).(*pgconn.PgError); ok && !t.noRetry && i < txMaxRetries-1 {
+			switch pgErr.Code {
+			case pgerrcode.SerializationFailure:
+				if t.sleep(time.Duration(100 + (500*rand.Float64())*float64(time.Millisecond))) {
+					continue
+				}
+			case pgerrcode.ReadOnlySQLTransaction:
+				// A read-write transaction on the primary can only be read-only if the primary was demoted, provided
+				// that the target session attributes only accept a read-write server: reconnect to pick the new one.
+				// Otherwise, the error is the caller's (e.g. read-only database or user).
+				if !t.readOnly && r == nil && inst.primaryReadWrite {
+					inst.primary.closeIdleConns(t.ctx)
+					if t.sleep(time.Duration(i+1) * txFailoverBackoff) {
+						continue
+					}
+				}
+			case pgerrcode.AdminShutdown:
+				// If the connection was terminated while committing, the outcome is unknown: it cannot be retried.
+				if committing {
+					break
+				}
+				if r != nil {
+					// The replica is shutting down: stop using it until it passes a health check again.
+					r.setHealthy(false)
+					r.closeIdleConns(t.ctx)
+				} else {
+					// The primary is shutting down (e.g. on failover): reconnect, which may pick a new one.
+					inst.primary.closeIdleConns(t.ctx)
+				}
+				if t.sleep(time.Duration(i+1) * txFailoverBackoff) {
+					continue
+				}
+			}
 		}
 
 		return errorz.Wrap(err, errorz.SkipPackage())
@@ -78,21 +126,61 @@ func (t *Tx) Run(f func(ctx context.Context) error) error {
 	panic("unreachable")
 }
 
-func (t *Tx) runTxOnce(f func(ctx context.Context) error) error {
-	tx, err := t.ctx.Value(dbContextKey).(*sql.DB).BeginTx(t.ctx, &sql.TxOptions{
-		Isolation: t.isolationLevel,
-		ReadOnly:  t.readOnly,
-	})
+// runTxOnce runs the transaction once, returning the replica it ran on (or nil if it ran on the primary), and whether
+// the error (if any) was returned by the commit.
+func (t *Tx) runTxOnce(inst *instance, f func(ctx context.Context) error) (*replica, bool, error) {
+	tx, r, err := t.beginTx(inst)
 	if err != nil {
-		return errorz.Wrap(err, errorz.SkipPackage())
+		return r, false, errorz.Wrap(err, errorz.SkipPackage())
 	}
 	defer func() {
-		_ = tx.Rollback()
+		_ = tx.rollback(t.ctx)
 	}()
 
-	if err := f(context.WithValue(t.ctx, dbContextKey, tx)); err != nil {
-		return errorz.Wrap(err, errorz.SkipPackage())
+	if err := f(tx.inject(t.ctx, t.name)); err != nil {
+		return r, false, errorz.Wrap(err, errorz.SkipPackage())
+	}
+
+	if err := tx.commit(t.ctx); err != nil {
+		return r, true, errorz.Wrap(err, errorz.SkipPackage())
 	}
+	return r, false, nil
+}
+
+// sleep waits for the given duration before retrying, returns false if the context is done first.
+func (t *Tx) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-t.ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
 
-	return errorz.MaybeWrap(tx.Commit(), errorz.SkipPackage())
+// beginTx begins a transaction on a healthy replica if read-only, falling back to the primary. It returns the replica
+// the transaction runs on, or nil if it runs on the primary.
+func (t *Tx) beginTx(inst *instance) (*backendTx, *replica, error) {
+	if t.readOnly {
+		if r := inst.replicas.pick(); r != nil {
+			if tx, err := r.beginTx(t.ctx, t.isolationLevel, t.readOnly); err == nil {
+				return tx, r, nil
+			} else if t.ctx.Err() == nil {
+				r.setHealthy(false)
+			}
+		}
+	}
+
+	tx, err := inst.primary.beginTx(t.ctx, t.isolationLevel, t.readOnly)
+	return tx, nil, errorz.MaybeWrap(err, errorz.SkipPackage())
+}
+
+func isInTx(ctx context.Context, name string) bool {
+	if pg, ok := ctx.Value(dbContextKey.named(name)).(*wrappedPG); ok && pg.isTx() {
+		return true
+	}
+	pgxPG, ok := ctx.Value(pgxContextKey.named(name)).(*wrappedPgxPG)
+	return ok && pgxPG.isTx()
 }
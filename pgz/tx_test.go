@@ -121,9 +121,7 @@ func dropTable(ctx context.Context, t *testing.T) {
 }
 
 func readCounter(ctx context.Context, t *testing.T) int64 {
-	var counter int64
-	row := pgz.GetCtx(ctx).QueryRow(`SELECT counter FROM test_transaction WHERE id = 0`)
-	fixturez.RequireNoError(t, row.Err())
-	fixturez.RequireNoError(t, row.Scan(&counter))
+	counter, err := pgz.SelectScalar[int64](ctx, `SELECT counter FROM test_transaction WHERE id = 0`)
+	fixturez.RequireNoError(t, err)
 	return counter
 }
@@ -0,0 +1,311 @@
+package pgz
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+
+	"github.com/ibrt/golang-errors/errorz"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/stdlib"
+)
+
+var (
+	_ PG    = &wrappedPG{}
+	_ PgxPG = &wrappedPgxPG{}
+)
+
+// sqlPG describes the common subset of *sql.DB and *sql.Tx.
+type sqlPG interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// pgxPG describes the common subset of *pgxpool.Pool and pgx.Tx.
+type pgxPG interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+}
+
+// wrappedPG wraps a *sql.DB or *sql.Tx, tracking queries until they return (or until their rows are closed) and
+// notifying observers.
+// For a *sql.Tx, conn is the *sql.Conn it runs on.
+type wrappedPG struct {
+	pg        sqlPG
+	conn      *sql.Conn
+	tracker   *tracker
+	observers []Observer
+}
+
+func newWrappedPG(pg sqlPG, tracker *tracker, observers []Observer) *wrappedPG {
+	return &wrappedPG{
+		pg:        pg,
+		tracker:   tracker,
+		observers: observers,
+	}
+}
+
+func (p *wrappedPG) isTx() bool {
+	_, ok := p.pg.(*sql.Tx)
+	return ok
+}
+
+// Exec implements the ContextPG interface.
+func (p *wrappedPG) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return p.ExecContext(context.Background(), query, args...)
+}
+
+// Query implements the ContextPG interface.
+func (p *wrappedPG) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return p.QueryContext(context.Background(), query, args...)
+}
+
+// QueryRow implements the ContextPG interface.
+func (p *wrappedPG) QueryRow(query string, args ...interface{}) *sql.Row {
+	return p.QueryRowContext(context.Background(), query, args...)
+}
+
+// ExecContext implements the PG interface.
+func (p *wrappedPG) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	defer p.tracker.trackQuery(query)()
+	end := observeQuery(ctx, p.observers, p.isTx(), query, args)
+	res, err := p.pg.ExecContext(ctx, query, args...)
+	end(sqlRowsAffected(res), err)
+	return res, err
+}
+
+// QueryContext implements the PG interface.
+func (p *wrappedPG) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	endQuery := p.tracker.trackQuery(query)
+	end := observeQuery(ctx, p.observers, p.isTx(), query, args)
+	rows, err := p.pg.QueryContext(context.WithValue(ctx, endRowsContextKey{}, endQuery), query, args...)
+	end(-1, err)
+	if err != nil {
+		endQuery()
+	}
+	return rows, err
+}
+
+// QueryRowContext implements the PG interface.
+func (p *wrappedPG) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	endQuery := p.tracker.trackQuery(query)
+	end := observeQuery(ctx, p.observers, p.isTx(), query, args)
+	row := p.pg.QueryRowContext(context.WithValue(ctx, endRowsContextKey{}, endQuery), query, args...)
+	end(-1, row.Err())
+	if row.Err() != nil {
+		endQuery()
+	}
+	return row
+}
+
+// NamedExec implements the ContextPG interface.
+func (p *wrappedPG) NamedExec(query string, arg interface{}) (sql.Result, error) {
+	return p.NamedExecContext(context.Background(), query, arg)
+}
+
+// NamedQuery implements the ContextPG interface.
+func (p *wrappedPG) NamedQuery(query string, arg interface{}) (*sql.Rows, error) {
+	return p.NamedQueryContext(context.Background(), query, arg)
+}
+
+// NamedExecContext implements the PG interface.
+func (p *wrappedPG) NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error) {
+	query, args, err := bindNamed(query, arg)
+	if err != nil {
+		return nil, errorz.Wrap(err, errorz.SkipPackage())
+	}
+	return p.ExecContext(ctx, query, args...)
+}
+
+// NamedQueryContext implements the PG interface.
+func (p *wrappedPG) NamedQueryContext(ctx context.Context, query string, arg interface{}) (*sql.Rows, error) {
+	query, args, err := bindNamed(query, arg)
+	if err != nil {
+		return nil, errorz.Wrap(err, errorz.SkipPackage())
+	}
+	return p.QueryContext(ctx, query, args...)
+}
+
+// wrappedPgxPG wraps a *pgxpool.Pool or pgx.Tx, tracking queries until they return (or until their rows are closed) and
+// notifying observers.
+type wrappedPgxPG struct {
+	pg        pgxPG
+	tracker   *tracker
+	observers []Observer
+}
+
+func newWrappedPgxPG(pg pgxPG, tracker *tracker, observers []Observer) *wrappedPgxPG {
+	return &wrappedPgxPG{
+		pg:        pg,
+		tracker:   tracker,
+		observers: observers,
+	}
+}
+
+func (p *wrappedPgxPG) isTx() bool {
+	_, ok := p.pg.(pgx.Tx)
+	return ok
+}
+
+// Exec implements the PgxPG interface.
+func (p *wrappedPgxPG) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	defer p.tracker.trackQuery(sql)()
+	end := observeQuery(ctx, p.observers, p.isTx(), sql, args)
+	tag, err := p.pg.Exec(ctx, sql, args...)
+	end(pgxRowsAffected(tag, err), err)
+	return tag, err
+}
+
+// Query implements the PgxPG interface.
+func (p *wrappedPgxPG) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	endQuery := p.tracker.trackQuery(sql)
+	end := observeQuery(ctx, p.observers, p.isTx(), sql, args)
+	rows, err := p.pg.Query(ctx, sql, args...)
+	end(-1, err)
+	if err != nil {
+		endQuery()
+		return rows, err
+	}
+	return &trackedPgxRows{Rows: rows, end: endQuery}, nil
+}
+
+// QueryRow implements the PgxPG interface.
+func (p *wrappedPgxPG) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	endQuery := p.tracker.trackQuery(sql)
+	end := observeQuery(ctx, p.observers, p.isTx(), sql, args)
+	row := p.pg.QueryRow(ctx, sql, args...)
+
+	return &trackedPgxRow{
+		Row: row,
+		end: func(err error) {
+			end(-1, err)
+			endQuery()
+		},
+	}
+}
+
+// SendBatch implements the PgxPG interface.
+func (p *wrappedPgxPG) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	return &trackedBatchResults{
+		BatchResults: p.pg.SendBatch(ctx, b),
+		end:          p.tracker.trackQuery(""),
+	}
+}
+
+// CopyFrom implements the PgxPG interface.
+func (p *wrappedPgxPG) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	query := getCopyQuery(tableName, columnNames)
+	defer p.tracker.trackQuery(query)()
+	end := observeQuery(ctx, p.observers, p.isTx(), query, nil)
+	n, err := p.pg.CopyFrom(ctx, tableName, columnNames, rowSrc)
+	if err != nil {
+		end(-1, err)
+	} else {
+		end(n, nil)
+	}
+	return n, err
+}
+
+// NamedExec implements the PgxPG interface.
+func (p *wrappedPgxPG) NamedExec(ctx context.Context, sql string, arg interface{}) (pgconn.CommandTag, error) {
+	sql, args, err := bindNamed(sql, arg)
+	if err != nil {
+		return nil, errorz.Wrap(err, errorz.SkipPackage())
+	}
+	return p.Exec(ctx, sql, args...)
+}
+
+// NamedQuery implements the PgxPG interface.
+func (p *wrappedPgxPG) NamedQuery(ctx context.Context, sql string, arg interface{}) (pgx.Rows, error) {
+	sql, args, err := bindNamed(sql, arg)
+	if err != nil {
+		return nil, errorz.Wrap(err, errorz.SkipPackage())
+	}
+	return p.Query(ctx, sql, args...)
+}
+
+type endRowsContextKey struct{}
+
+// trackedConn wraps a *stdlib.Conn, so that the query found in the context of QueryContext (if any) is tracked until
+// the returned rows are closed.
+type trackedConn struct {
+	*stdlib.Conn
+}
+
+// QueryContext implements the driver.QueryerContext interface. Errors are returned as-is, as database/sql checks them
+// for driver.ErrBadConn.
+func (c *trackedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := c.Conn.QueryContext(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if end, ok := ctx.Value(endRowsContextKey{}).(func()); ok {
+		return &trackedRows{Rows: rows.(*stdlib.Rows), end: end}, nil
+	}
+
+	return rows, nil
+}
+
+// trackedRows wraps a *stdlib.Rows, calling end when closed.
+type trackedRows struct {
+	*stdlib.Rows
+	end func()
+}
+
+// Close implements the driver.Rows interface.
+func (r *trackedRows) Close() error {
+	defer r.end()
+	return r.Rows.Close()
+}
+
+// trackedPgxRows wraps a pgx.Rows, calling end when closed, including implicitly after reading all rows.
+type trackedPgxRows struct {
+	pgx.Rows
+	end func()
+}
+
+// Next implements the pgx.Rows interface.
+func (r *trackedPgxRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	r.end()
+	return false
+}
+
+// Close implements the pgx.Rows interface.
+func (r *trackedPgxRows) Close() {
+	r.Rows.Close()
+	r.end()
+}
+
+// trackedPgxRow wraps a pgx.Row, calling end with the outcome of the query when scanned.
+type trackedPgxRow struct {
+	pgx.Row
+	end func(err error)
+}
+
+// Scan implements the pgx.Row interface.
+func (r *trackedPgxRow) Scan(dest ...interface{}) error {
+	err := r.Row.Scan(dest...)
+	r.end(err)
+	return err
+}
+
+// trackedBatchResults wraps a pgx.BatchResults, calling end when closed.
+type trackedBatchResults struct {
+	pgx.BatchResults
+	end func()
+}
+
+// Close implements the pgx.BatchResults interface.
+func (r *trackedBatchResults) Close() error {
+	defer r.end()
+	return r.BatchResults.Close()
+}